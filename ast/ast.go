@@ -6,6 +6,7 @@
 package ast
 
 import (
+	"crypto/sha256"
 	"github.com/cyevgeniy/pldoc/token"
 	"strings"
 )
@@ -112,13 +113,23 @@ func (g *CommentGroup) Text() string {
 	return strings.Join(lines, "\n")
 }
 
+// Identifier, or a piece of source text like a type or a default
+// value. Such text is composed from several tokens, and its Name may
+// be shorter or longer than the source, so Last has to be set.
 type Ident struct {
 	Name  string
 	First token.Pos
+	Last  token.Pos // Position immediately after the text; NoPos for plain identifiers
 }
 
 func (i *Ident) Start() token.Pos { return i.First }
-func (i *Ident) End() token.Pos   { return token.Pos(int(i.First) + len(i.Name)) }
+func (i *Ident) End() token.Pos {
+	if i.Last.IsValid() {
+		return i.Last
+	}
+
+	return token.Pos(int(i.First) + len(i.Name))
+}
 func (i *Ident) String() string {
 	if i != nil {
 		return i.Name
@@ -162,17 +173,28 @@ const (
 )
 
 type Field struct {
-	Doc  *CommentGroup
-	Name *Ident
-	T    *Ident
-	Kind VarType  // constant, variable, exception or parameter
-	Mod  FieldMod // IN, OUT, or IN OUT param. modNone for variable declaration
-	Def  *Ident   // Default value. Nil for exceptions
-	Null bool     // not null modificator. Used only in Variable declarations
+	Doc    *CommentGroup
+	Name   *Ident
+	T      *Ident
+	Kind   VarType  // constant, variable, exception or parameter
+	Mod    FieldMod // IN, OUT, or IN OUT param. modNone for variable declaration
+	NoCopy bool     // NOCOPY hint. Used only in OUT and IN OUT parameters
+	Def    *Ident   // Default value. Nil for exceptions
+	Null   bool     // NOT NULL constraint. Used only in variable and constant declarations
+	Code   *Ident   // Oracle error code from PRAGMA EXCEPTION_INIT. Used only in exceptions
 }
 
 func (f *Field) Start() token.Pos { return f.Name.Start() }
-func (f *Field) End() token.Pos   { return f.T.End() }
+func (f *Field) End() token.Pos {
+	switch {
+	case f.Def != nil:
+		return f.Def.End()
+	case f.T != nil:
+		return f.T.End()
+	}
+
+	return f.Name.End()
+}
 func (f *Field) String() string {
 	if f.Name == nil {
 		return ""
@@ -185,12 +207,17 @@ func (f *Field) String() string {
 
 	var s string
 
-	if f.Kind == VVar {
-		s = f.Name.String() + " " + f.T.String()
-	}
+	if f.Kind == VVar || f.Kind == VConst {
+		s = f.Name.String() + " "
+		if f.Kind == VConst {
+			s += "constant "
+		}
+
+		s += f.T.String()
+		if f.Null {
+			s += " not null"
+		}
 
-	if f.Kind == VConst {
-		s = f.Name.String() + " constant " + f.T.String()
 		if f.Def != nil {
 			s += " := " + f.Def.String()
 		}
@@ -208,6 +235,10 @@ func (f *Field) String() string {
 			modStr = " " + f.Mod.String() + " "
 		}
 
+		if f.NoCopy {
+			modStr += "nocopy "
+		}
+
 		s = f.Name.String() + modStr + f.T.String()
 		if f.Def != nil {
 			s += " default " + f.Def.String()
@@ -230,17 +261,21 @@ func (l *FieldList) End() token.Pos   { return l.Closing + 1 }
 
 // Subtype declaration
 type SubtypeDecl struct {
-	Doc  *CommentGroup
-	Name *Ident
-	Base *Ident // base type
+	Doc     *CommentGroup
+	First   token.Pos // Position of the 'subtype' token
+	Last    token.Pos // Position immediately after the declaration, excluding the semicolon
+	Name    *Ident
+	Base    *Ident // base type, including its size or precision constraint
+	Range   *Ident // range constraint, like 0..100. Nil if not specified
+	NotNull bool   // not null constraint
 }
 
 func (s *SubtypeDecl) Start() token.Pos {
-	return s.Name.Start()
+	return s.First
 }
 
 func (s *SubtypeDecl) End() token.Pos {
-	return s.Base.End()
+	return s.Last
 }
 
 // Represents raw SQL text.
@@ -260,6 +295,8 @@ func (s *Sql) End() token.Pos {
 // Cursor declaration
 type CursorDecl struct {
 	Doc    *CommentGroup
+	First  token.Pos // Position of the 'cursor' token
+	Last   token.Pos // Position immediately after the declaration, excluding the semicolon
 	Name   *Ident
 	Params *FieldList
 	T      *Ident
@@ -267,11 +304,11 @@ type CursorDecl struct {
 }
 
 func (c *CursorDecl) Start() token.Pos {
-	return c.Name.Start()
+	return c.First
 }
 
 func (c *CursorDecl) End() token.Pos {
-	return c.SQL.End()
+	return c.Last
 }
 
 type FuncType byte
@@ -281,24 +318,95 @@ const (
 	FtProc
 )
 
+// Pragma, like pragma exception_init(e_not_found, -20001)
+type Pragma struct {
+	Doc   *CommentGroup
+	First token.Pos // Position of the 'pragma' token
+	Last  token.Pos // Position immediately after the pragma, excluding the semicolon
+	Name  *Ident
+	Args  []*Ident // Arguments as they are written in the source
+}
+
+func (pr *Pragma) Start() token.Pos {
+	return pr.First
+}
+
+func (pr *Pragma) End() token.Pos {
+	return pr.Last
+}
+
+// Returns the first pragma's argument, which usually is the name
+// of the declaration the pragma refers to. Returns an empty string
+// if the pragma doesn't have arguments.
+func (pr *Pragma) Target() string {
+	if len(pr.Args) == 0 {
+		return ""
+	}
+
+	return pr.Args[0].Name
+}
+
+// Returns the first pragma with the specified name
+// from the list, or nil if there is no such pragma
+func FindPragma(list []*Pragma, name string) *Pragma {
+	for i := range list {
+		if list[i].Name.Name == name {
+			return list[i]
+		}
+	}
+
+	return nil
+}
+
+// Unit in the ACCESSIBLE BY clause
+type Accessor struct {
+	Kind   string // function, procedure, package, trigger or type. Empty if not specified
+	Schema *Ident // Nil if not specified
+	Name   *Ident
+}
+
+func (a *Accessor) String() string {
+	var s string
+
+	if a.Kind != "" {
+		s = a.Kind + " "
+	}
+
+	if a.Schema != nil {
+		s += a.Schema.Name + "."
+	}
+
+	return s + a.Name.String()
+}
+
 // Function specification
 type FuncSpec struct {
-	Doc           *CommentGroup
-	Name          *Ident
-	Params        *FieldList
-	Ftype         FuncType
-	Pipelined     bool   // ignored for procedures
-	Deterministic bool   // ignored for procedures
-	ResultCache   bool   // ignored for procedures
-	T             *Ident // ignored for procedures
+	Doc            *CommentGroup
+	First          token.Pos // Position of the 'function' or 'procedure' token
+	Last           token.Pos // Position immediately after the specification
+	Name           *Ident
+	Params         *FieldList
+	Ftype          FuncType
+	Pipelined      bool        // ignored for procedures
+	PipelinedUsing *Ident      // implementation type of a pipelined function. Nil if not specified
+	Deterministic  bool        // ignored for procedures
+	ResultCache    bool        // ignored for procedures
+	ReliesOn       []*Ident    // objects from the RELIES_ON clause of RESULT_CACHE
+	ParallelEnable bool        // ignored for procedures
+	Partition      *Ident      // partitioning clause of PARALLEL_ENABLE, like "partition p by any"
+	AggregateUsing *Ident      // implementation type of an aggregate function
+	SQLMacro       *Ident      // "table" or "scalar" for SQL macros, nil otherwise
+	AccessibleBy   []*Accessor // units from the ACCESSIBLE BY clause
+	Pragmas        []*Pragma   // pragmas that refer to the subprogram
+	T              *Ident      // ignored for procedures
 }
 
 func (f *FuncSpec) Start() token.Pos {
-	return f.Name.Start()
+	return f.First
 }
 
 func (f *FuncSpec) End() token.Pos {
-	return f.T.End()
+	return f.Last
 }
 
 // Table type or Varray type
@@ -313,6 +421,8 @@ const (
 
 type TypeDecl struct {
 	Doc    *CommentGroup
+	First  token.Pos // Position of the 'type' token
+	Last   token.Pos // Position immediately after the declaration, excluding the semicolon
 	Name   *Ident
 	Kind   TypeKind
 	T      *Ident // for all remaining part of table or varray or ref_cursor declaration
@@ -320,25 +430,47 @@ type TypeDecl struct {
 }
 
 func (l *TypeDecl) Start() token.Pos {
-	return l.Name.First
+	return l.First
 }
 
 func (l *TypeDecl) End() token.Pos {
-	return l.T.End()
+	return l.Last
 }
 
 // Package specification
 type Package struct {
 	Doc   *CommentGroup
 	First token.Pos // Position of the 'package' token
-	Last  token.Pos // Next token after ...end pck_name(most probably semicolon)
+	Last  token.Pos // Position immediately after ...end pck_name
 	Name  *Ident
 
+	Schema       *Ident      // Owner schema; nil if it's not specified
+	Edition      *Ident      // editionable or noneditionable; nil if it's not specified
+	Sharing      *Ident      // metadata or none from the sharing clause
+	AuthID       *Ident      // current_user or definer; nil if it's not specified
+	AccessibleBy []*Accessor // units from the ACCESSIBLE BY clause
+
 	VarDecls     []*Field
 	SubtypeDecls []*SubtypeDecl
 	FuncSpecs    []*FuncSpec
 	CursorDecls  []*CursorDecl
 	TypeDecls    []*TypeDecl
+	Pragmas      []*Pragma // All pragmas of the specification
+}
+
+// Returns pragmas that refer to the package itself, like
+// serially_reusable or deprecate(pck_name)
+func (p *Package) PackagePragmas() []*Pragma {
+	var res []*Pragma
+
+	for _, pr := range p.Pragmas {
+		target := pr.Target()
+		if target == "" || target == "default" || target == p.Name.Name {
+			res = append(res, pr)
+		}
+	}
+
+	return res
 }
 
 func (p *Package) Start() token.Pos {
@@ -349,21 +481,108 @@ func (p *Package) End() token.Pos {
 	return p.Last
 }
 
+type MethodKind byte
+
+const (
+	MkMember MethodKind = iota
+	MkStatic
+	MkConstructor
+)
+
+func (mk MethodKind) String() string {
+	switch mk {
+	case MkMember:
+		return "member"
+	case MkStatic:
+		return "static"
+	case MkConstructor:
+		return "constructor"
+	}
+
+	return ""
+}
+
+// Method of an object type
+type Method struct {
+	First        token.Pos // position of the first method's modifier
+	Kind         MethodKind
+	Map          bool // MAP member function
+	Order        bool // ORDER member function
+	Overriding   bool
+	Final        bool
+	Instantiable bool      // false for NOT INSTANTIABLE methods
+	Spec         *FuncSpec // method's name, parameters and documentation
+}
+
+func (m *Method) Start() token.Pos {
+	return m.First
+}
+
+func (m *Method) End() token.Pos {
+	return m.Spec.End()
+}
+
+// Object type specification
+type ObjectType struct {
+	Doc          *CommentGroup
+	First        token.Pos // Position of the 'type' token
+	Last         token.Pos // Position immediately after the specification, excluding the semicolon
+	Name         *Ident
+	Super        *Ident   // Supertype of the type declared with UNDER
+	Attrs        []*Field // Attributes
+	Methods      []*Method
+	Final        bool // false for NOT FINAL types
+	Instantiable bool // false for NOT INSTANTIABLE types
+}
+
+func (t *ObjectType) Start() token.Pos {
+	return t.First
+}
+
+func (t *ObjectType) End() token.Pos {
+	return t.Last
+}
+
 // File
 type File struct {
-	Name     string
-	Packages []*Package
+	Name      string
+	FileStart token.Pos         // Start of the file, to look it up in the file set
+	Sum       [sha256.Size]byte // Checksum of the source the file was parsed from
+	Packages  []*Package
+	Types     []*ObjectType
+	Funcs     []*FuncSpec // Standalone functions and procedures
 }
 
 type Files struct {
 	Description string
 	Files       []*File
+	FileSet     *token.FileSet // Positions of all nodes in Files
 }
 
 func (fset *Files) Add(f *File) {
 	fset.Files = append(fset.Files, f)
 }
 
+func (fset *Files) GetTypes() []*ObjectType {
+	res := make([]*ObjectType, 0)
+
+	for i := range fset.Files {
+		res = append(res, fset.Files[i].Types...)
+	}
+
+	return res
+}
+
+func (fset *Files) GetFuncs() []*FuncSpec {
+	res := make([]*FuncSpec, 0)
+
+	for i := range fset.Files {
+		res = append(res, fset.Files[i].Funcs...)
+	}
+
+	return res
+}
+
 func (fset *Files) GetPackages() []*Package {
 	res := make([]*Package, 0)
 
@@ -0,0 +1,379 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package doc
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Comment is the parsed text of a doc comment.
+// The output formats render the same blocks,
+// so comments look alike in all of them.
+type Comment struct {
+	Blocks []Block
+}
+
+// A block is a *Paragraph, a *Heading, a *List or a *Code
+type Block interface {
+	block()
+}
+
+// Lines of text, separated from other blocks with blank lines
+type Paragraph struct {
+	Text []Text
+}
+
+// A line that starts with "# "
+type Heading struct {
+	Text []Text
+}
+
+// Consecutive lines that start with "-", "*" or "+" for bullet
+// lists, or with a number followed by "." or ")" for numbered lists
+type List struct {
+	Ordered bool
+	Items   []*ListItem
+}
+
+type ListItem struct {
+	Number string // only in numbered lists, like "1"
+	Text   []Text
+}
+
+// Lines that are indented more than the comment's
+// first line. They are shown as is.
+type Code struct {
+	Text string // without the common indentation and the final newline
+}
+
+func (*Paragraph) block() {}
+func (*Heading) block()   {}
+func (*List) block()      {}
+func (*Code) block()      {}
+
+// A text is a Plain, a CodeSpan, a *Link or a *DocLink
+type Text interface {
+	text()
+}
+
+// Plain text
+type Plain string
+
+// Text in backticks, like `null`
+type CodeSpan string
+
+// A URL in the text, like https://example.com
+type Link struct {
+	URL string
+}
+
+// A reference to a declaration in brackets, like [pck.name]
+type DocLink struct {
+	Name string // without the brackets
+}
+
+func (Plain) text()    {}
+func (CodeSpan) text() {}
+func (*Link) text()    {}
+func (*DocLink) text() {}
+
+// Bullet or number that starts a list item, followed
+// by a space or the end of the line
+var listMarkerRe = regexp.MustCompile(`^(?:[-*+]|([0-9]+)[.)])(?:\s+|$)`)
+
+// Spans of text that are parsed: code in backticks, URLs and doc links
+// to identifiers and qualified names, like t_rec, pck.t_tab or "MyType"
+var inlineRe = regexp.MustCompile("`[^`]+`" +
+	`|https?://[^\s<>"]+` +
+	`|\[((?:[A-Za-z_][\w$#]*|"[^"]+")(?:\.(?:[A-Za-z_][\w$#]*|"[^"]+"))*)\]`)
+
+// Returns the indentation of the line, or -1 if it's blank
+func indent(line string) int {
+	for i := range line {
+		if line[i] != ' ' && line[i] != '\t' {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Returns the list item's marker and its number,
+// or an empty marker if the line isn't a list item
+func listMarker(line string) (marker string, number string) {
+	m := listMarkerRe.FindStringSubmatch(line)
+	if m == nil {
+		return "", ""
+	}
+
+	return m[0], m[1]
+}
+
+func isHeading(line string) bool {
+	return strings.HasPrefix(line, "# ")
+}
+
+// Parses the comment's text. Lines that are indented more than
+// the first line are code blocks, as in earlier versions of pldoc.
+// Other lines are split into paragraphs by blank lines, and lines
+// that start with "# " or with list markers start headings and
+// list items. Lines that follow a list item and don't start another
+// one continue the item until a blank line.
+func Parse(text string) *Comment {
+	c := &Comment{}
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+
+	base := -1
+	for _, line := range lines {
+		if base = indent(line); base >= 0 {
+			break
+		}
+	}
+
+	if base < 0 {
+		return c
+	}
+
+	for i := 0; i < len(lines); {
+		ind := indent(lines[i])
+		line := strings.TrimSpace(lines[i])
+
+		switch {
+		case ind < 0:
+			i++
+
+		case ind > base:
+			c.Blocks = append(c.Blocks, parseCode(lines, &i, base))
+
+		case isHeading(line):
+			c.Blocks = append(c.Blocks, &Heading{Text: parseText(strings.TrimSpace(line[2:]))})
+			i++
+
+		default:
+			if marker, _ := listMarker(line); marker != "" {
+				c.Blocks = append(c.Blocks, parseList(lines, &i, base))
+			} else {
+				c.Blocks = append(c.Blocks, parseParagraph(lines, &i, base))
+			}
+		}
+	}
+
+	return c
+}
+
+// Parses the code block that starts at the line *i
+func parseCode(lines []string, i *int, base int) *Code {
+	var block []string
+
+	for ; *i < len(lines); *i++ {
+		ind := indent(lines[*i])
+		if ind >= 0 && ind <= base {
+			break
+		}
+
+		block = append(block, lines[*i])
+	}
+
+	// Blank lines separate the block from the following text
+	for len(block) > 0 && indent(block[len(block)-1]) < 0 {
+		block = block[:len(block)-1]
+	}
+
+	common := -1
+	for _, line := range block {
+		if ind := indent(line); ind >= 0 && (common < 0 || ind < common) {
+			common = ind
+		}
+	}
+
+	for j, line := range block {
+		if len(line) > common {
+			block[j] = line[common:]
+		} else {
+			block[j] = ""
+		}
+	}
+
+	return &Code{Text: strings.Join(block, "\n")}
+}
+
+// Parses the paragraph that starts at the line *i. It ends
+// with a blank line, a code block, a heading or a list.
+func parseParagraph(lines []string, i *int, base int) *Paragraph {
+	var para []string
+
+	for ; *i < len(lines); *i++ {
+		ind := indent(lines[*i])
+		line := strings.TrimSpace(lines[*i])
+
+		if len(para) > 0 {
+			if marker, _ := listMarker(line); marker != "" {
+				break
+			}
+		}
+
+		if ind < 0 || ind > base || isHeading(line) {
+			break
+		}
+
+		para = append(para, line)
+	}
+
+	return &Paragraph{Text: parseText(strings.Join(para, "\n"))}
+}
+
+// Parses the list that starts at the line *i. Blank lines between
+// items are allowed, the list ends with any other blank line.
+func parseList(lines []string, i *int, base int) *List {
+	list := &List{}
+	var item []string
+	var number string
+
+	flush := func() {
+		if len(item) > 0 {
+			list.Items = append(list.Items, &ListItem{Number: number, Text: parseText(strings.Join(item, "\n"))})
+		}
+		item = nil
+	}
+
+	for *i < len(lines) {
+		ind := indent(lines[*i])
+		line := strings.TrimSpace(lines[*i])
+
+		if ind < 0 {
+			// The list goes on if the next line starts another item
+			next := *i + 1
+			for next < len(lines) && indent(lines[next]) < 0 {
+				next++
+			}
+
+			if next == len(lines) || indent(lines[next]) > base || !startsItem(list, strings.TrimSpace(lines[next])) {
+				break
+			}
+
+			*i = next
+			continue
+		}
+
+		if ind <= base && isHeading(line) {
+			break
+		}
+
+		if marker, num := listMarker(line); marker != "" && ind <= base {
+			if len(list.Items) == 0 && len(item) == 0 {
+				list.Ordered = num != ""
+			} else if list.Ordered != (num != "") {
+				break
+			}
+
+			flush()
+			number = num
+			line = strings.TrimSpace(line[len(marker):])
+		}
+
+		item = append(item, line)
+		*i++
+	}
+
+	flush()
+
+	return list
+}
+
+// Reports whether the line starts an item of the same kind as the list's
+func startsItem(list *List, line string) bool {
+	marker, num := listMarker(line)
+	return marker != "" && list.Ordered == (num != "")
+}
+
+// Parses code spans, URLs and doc links in the text. Like in Go
+// doc comments, a doc link must not be glued to a word, so array
+// indexes like a[i] are not links, and it must not be followed
+// by a parenthesis, so Markdown-style links like [text](url)
+// aren't either.
+func parseText(text string) []Text {
+	var res []Text
+
+	prev := 0
+	for _, loc := range inlineRe.FindAllStringSubmatchIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		var t Text
+
+		switch {
+		case text[start] == '`':
+			t = CodeSpan(text[start+1 : end-1])
+
+		case text[start] == '[':
+			if start > 0 && isWordByte(text[start-1]) ||
+				end < len(text) && (isWordByte(text[end]) || text[end] == '(' || text[end] == '[') {
+				continue
+			}
+
+			t = &DocLink{Name: text[loc[2]:loc[3]]}
+
+		default:
+			end = start + len(trimURL(text[start:end]))
+			t = &Link{URL: text[start:end]}
+		}
+
+		if prev < start {
+			res = append(res, Plain(text[prev:start]))
+		}
+
+		res = append(res, t)
+		prev = end
+	}
+
+	if prev < len(text) {
+		res = append(res, Plain(text[prev:]))
+	}
+
+	return res
+}
+
+// Trims punctuation that ends the sentence rather than the URL.
+// A closing parenthesis is kept if the URL has the opening one.
+func trimURL(url string) string {
+	for len(url) > 0 {
+		c := url[len(url)-1]
+
+		if c == ')' && strings.Count(url, "(") >= strings.Count(url, ")") {
+			break
+		}
+
+		if !strings.ContainsRune(".,:;!?')", rune(c)) {
+			break
+		}
+
+		url = url[:len(url)-1]
+	}
+
+	return url
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == ']' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// Returns the text as it's written in the comment
+func TextString(text []Text) string {
+	var b strings.Builder
+
+	for _, t := range text {
+		switch t := t.(type) {
+		case Plain:
+			b.WriteString(string(t))
+		case CodeSpan:
+			b.WriteString("`" + string(t) + "`")
+		case *Link:
+			b.WriteString(t.URL)
+		case *DocLink:
+			b.WriteString("[" + t.Name + "]")
+		}
+	}
+
+	return b.String()
+}
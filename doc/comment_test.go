@@ -0,0 +1,131 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package doc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Block
+	}{
+		{
+			"paragraphs",
+			" First line\n second line\n\n Second paragraph\n",
+			[]Block{
+				&Paragraph{Text: []Text{Plain("First line\nsecond line")}},
+				&Paragraph{Text: []Text{Plain("Second paragraph")}},
+			},
+		},
+		{
+			"code",
+			" Output looks like:\n     key1: value 1\n       nested\n\n     key2: value 2\n Done\n",
+			[]Block{
+				&Paragraph{Text: []Text{Plain("Output looks like:")}},
+				&Code{Text: "key1: value 1\n  nested\n\nkey2: value 2"},
+				&Paragraph{Text: []Text{Plain("Done")}},
+			},
+		},
+		{
+			"heading",
+			" # Errors\n Raises errors.\n",
+			[]Block{
+				&Heading{Text: []Text{Plain("Errors")}},
+				&Paragraph{Text: []Text{Plain("Raises errors.")}},
+			},
+		},
+		{
+			"bullet list",
+			" Modes:\n - read\n * write,\n   or append\n\n + delete\n\n Text\n",
+			[]Block{
+				&Paragraph{Text: []Text{Plain("Modes:")}},
+				&List{Items: []*ListItem{
+					{Text: []Text{Plain("read")}},
+					{Text: []Text{Plain("write,\nor append")}},
+					{Text: []Text{Plain("delete")}},
+				}},
+				&Paragraph{Text: []Text{Plain("Text")}},
+			},
+		},
+		{
+			"numbered list",
+			" 1. first\n 2) second\n - other list\n",
+			[]Block{
+				&List{Ordered: true, Items: []*ListItem{
+					{Number: "1", Text: []Text{Plain("first")}},
+					{Number: "2", Text: []Text{Plain("second")}},
+				}},
+				&List{Items: []*ListItem{
+					{Text: []Text{Plain("other list")}},
+				}},
+			},
+		},
+		{
+			"inline",
+			" Returns `null` for [pck.name], see https://example.com/a_(b).\n",
+			[]Block{
+				&Paragraph{Text: []Text{
+					Plain("Returns "),
+					CodeSpan("null"),
+					Plain(" for "),
+					&DocLink{Name: "pck.name"},
+					Plain(", see "),
+					&Link{URL: "https://example.com/a_(b)"},
+					Plain("."),
+				}},
+			},
+		},
+		{
+			"not doc links",
+			" a[i], [text](url), `[pck.name]`\n",
+			[]Block{
+				&Paragraph{Text: []Text{
+					Plain("a[i], [text](url), "),
+					CodeSpan("[pck.name]"),
+				}},
+			},
+		},
+		{
+			"empty",
+			"\n  \n",
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		got := Parse(tt.text).Blocks
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\ngot  %s\nwant %s", tt.name, dump(got), dump(tt.want))
+		}
+	}
+}
+
+// Returns blocks' types and texts for error messages
+func dump(blocks []Block) string {
+	var s string
+
+	for _, b := range blocks {
+		switch b := b.(type) {
+		case *Paragraph:
+			s += "P(" + TextString(b.Text) + ") "
+		case *Heading:
+			s += "H(" + TextString(b.Text) + ") "
+		case *Code:
+			s += "C(" + b.Text + ") "
+		case *List:
+			s += "L("
+			for _, item := range b.Items {
+				s += item.Number + ":" + TextString(item.Text) + ";"
+			}
+			s += ") "
+		}
+	}
+
+	return s
+}
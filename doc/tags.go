@@ -0,0 +1,161 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package doc parses doc comments: their markup, like lists
+// and code blocks (see Parse), and PLDoc and JavaDoc style
+// tags, like:
+//
+//	Returns the user's name.
+//
+//	@param p_id The user's id
+//	@return The name, or null if there is no such user
+//	@throws e_not_found If the user is locked
+//	@see users_api.get_user
+//	@since 2.1
+//
+// A tag starts at the beginning of a line and lasts until
+// the next tag or the end of the comment. Lines that start
+// with unknown tags are ordinary text.
+package doc
+
+import (
+	"strings"
+)
+
+type Tag struct {
+	Name string // param, return, throws, see, since or deprecated
+	Arg  string // parameter's name for @param, exception's name for @throws, reference for @see
+	Text string // description, with lines joined by spaces
+}
+
+// Comment's text and its tags
+type Tags struct {
+	Text string // text before the first tag; newline-terminated unless empty
+	List []*Tag
+}
+
+// Names of known tags by their spellings. Aliases are
+// mapped to the names that are used in JavaDoc.
+var tagNames = map[string]string{
+	"param":      "param",
+	"return":     "return",
+	"returns":    "return",
+	"throws":     "throws",
+	"exception":  "throws",
+	"raises":     "throws",
+	"see":        "see",
+	"since":      "since",
+	"deprecated": "deprecated",
+}
+
+// Tags whose first word is the argument
+var argTags = map[string]bool{
+	"param":  true,
+	"throws": true,
+	"see":    true,
+}
+
+// Returns the tag that starts the line, or nil if the
+// line doesn't start with a known tag
+func parseTag(line string) *Tag {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "@") {
+		return nil
+	}
+
+	fields := strings.Fields(line[1:])
+	if len(fields) == 0 {
+		return nil
+	}
+
+	name, ok := tagNames[strings.ToLower(fields[0])]
+	if !ok {
+		return nil
+	}
+
+	tag := &Tag{Name: name}
+	fields = fields[1:]
+
+	if argTags[name] && len(fields) > 0 {
+		tag.Arg = fields[0]
+		fields = fields[1:]
+	}
+
+	tag.Text = strings.Join(fields, " ")
+
+	return tag
+}
+
+// ParseTags splits the comment's text, as returned by
+// ast.CommentGroup.Text, into the text and the tags
+func ParseTags(text string) *Tags {
+	var res Tags
+	var lines []string
+	var last *Tag
+
+	for _, line := range strings.Split(text, "\n") {
+		if tag := parseTag(line); tag != nil {
+			res.List = append(res.List, tag)
+			last = tag
+			continue
+		}
+
+		if last == nil {
+			lines = append(lines, line)
+		} else if s := strings.TrimSpace(line); s != "" {
+			// Continuation of the tag's description
+			if last.Text != "" {
+				last.Text += " "
+			}
+			last.Text += s
+		}
+	}
+
+	// Tags are usually separated from the text with
+	// an empty line, which isn't part of the text
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) > 0 {
+		res.Text = strings.Join(lines, "\n") + "\n"
+	}
+
+	return &res
+}
+
+// Returns all tags with the name
+func (t *Tags) Lookup(name string) []*Tag {
+	var res []*Tag
+	for _, tag := range t.List {
+		if tag.Name == name {
+			res = append(res, tag)
+		}
+	}
+
+	return res
+}
+
+// Returns the first tag with the name, or nil
+func (t *Tags) Find(name string) *Tag {
+	for _, tag := range t.List {
+		if tag.Name == name {
+			return tag
+		}
+	}
+
+	return nil
+}
+
+// Returns the @param tag for the parameter, or nil. Names
+// are compared case-insensitively, as PL/SQL names are.
+func (t *Tags) Param(name string) *Tag {
+	for _, tag := range t.List {
+		if tag.Name == "param" && strings.EqualFold(tag.Arg, name) {
+			return tag
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package doc
+
+import (
+	"testing"
+)
+
+var tagsSrc = `Returns the user's name.
+Second line.
+
+@param p_id The user's id,
+    which is positive
+@PARAM p_name
+@returns The name
+@raises e_not_found If there is no user
+@author Somebody
+@see users_api.get_user
+@since 2.1
+@deprecated
+`
+
+func TestParseTags(t *testing.T) {
+	tags := ParseTags(tagsSrc)
+
+	if want := "Returns the user's name.\nSecond line.\n"; tags.Text != want {
+		t.Errorf("Text: got %q, want %q", tags.Text, want)
+	}
+
+	want := []Tag{
+		{"param", "p_id", "The user's id, which is positive"},
+		{"param", "p_name", ""},
+		{"return", "", "The name"},
+		{"throws", "e_not_found", "If there is no user @author Somebody"},
+		{"see", "users_api.get_user", ""},
+		{"since", "", "2.1"},
+		{"deprecated", "", ""},
+	}
+
+	if len(tags.List) != len(want) {
+		t.Fatalf("got %d tags, want %d", len(tags.List), len(want))
+	}
+
+	for i := range want {
+		if *tags.List[i] != want[i] {
+			t.Errorf("tag %d: got %+v, want %+v", i, *tags.List[i], want[i])
+		}
+	}
+
+	if tag := tags.Param("P_NAME"); tag == nil || tag.Arg != "p_name" {
+		t.Errorf("Param(P_NAME): got %+v", tag)
+	}
+
+	if n := len(tags.Lookup("param")); n != 2 {
+		t.Errorf("Lookup(param): got %d tags, want 2", n)
+	}
+}
+
+func TestParseTagsWithoutTags(t *testing.T) {
+	text := "Just text.\n\n    preformatted\n"
+
+	tags := ParseTags(text)
+	if tags.Text != text || len(tags.List) != 0 {
+		t.Errorf("got %+v", tags)
+	}
+
+	if tags := ParseTags(""); tags.Text != "" || len(tags.List) != 0 {
+		t.Errorf("empty comment: got %+v", tags)
+	}
+}
@@ -0,0 +1,603 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package jsondoc writes the documentation model as JSON, so tools
+// that aren't written in Go can use the parser's results.
+//
+// The schema is versioned. Fields may be added in the same version,
+// but existing fields are never renamed, removed or changed in
+// meaning without incrementing Version. Optional strings, lists and
+// boolean flags are omitted when they are empty or false; the "final"
+// and "instantiable" flags of object types and methods are always
+// written, because they are true by default.
+//
+// The top-level object is:
+//
+//	{
+//	  "version": 1,
+//	  "files": [File]
+//	}
+//
+// File is a source file with the declarations it contains:
+//
+//	{
+//	  "path": string,            // file path relative to the source directory
+//	  "packages": [Package],
+//	  "types": [ObjectType],
+//	  "subprograms": [Subprogram] // standalone functions and procedures
+//	}
+//
+// Every declaration has "start" and "end" Positions. The end is the
+// position immediately after the declaration, excluding the semicolon.
+// Lines and columns start at 1, columns are counted in bytes:
+//
+//	{"line": int, "column": int}
+//
+// "doc" is the text of the declaration's comment without the comment
+// markers, as the HTML pages show it.
+//
+// Package:
+//
+//	{
+//	  "name": string, "schema": string, "doc": string,
+//	  "authid": string,           // current_user or definer
+//	  "edition": string,          // editionable or noneditionable
+//	  "sharing": string,          // metadata or none
+//	  "accessibleBy": [string],   // like "package api_pkg"
+//	  "pragmas": [Pragma],        // all pragmas of the specification
+//	  "variables": [Variable],
+//	  "subprograms": [Subprogram],
+//	  "types": [Type],
+//	  "subtypes": [Subtype],
+//	  "cursors": [Cursor],
+//	  "start": Position, "end": Position
+//	}
+//
+// Variable is a constant, variable or exception:
+//
+//	{
+//	  "name": string, "doc": string,
+//	  "kind": "constant" | "variable" | "exception",
+//	  "type": string, "default": string, "notNull": bool,
+//	  "errorCode": string,        // from pragma exception_init
+//	  "start": Position, "end": Position
+//	}
+//
+// Subprogram:
+//
+//	{
+//	  "name": string, "doc": string,
+//	  "kind": "function" | "procedure",
+//	  "params": [Param],
+//	  "returns": string,          // functions only
+//	  "deterministic": bool, "pipelined": bool, "pipelinedUsing": string,
+//	  "resultCache": bool, "reliesOn": [string],
+//	  "parallelEnable": bool, "partition": string,
+//	  "aggregateUsing": string, "sqlMacro": string,
+//	  "accessibleBy": [string],
+//	  "pragmas": [Pragma],        // pragmas that refer to the subprogram
+//	  "start": Position, "end": Position
+//	}
+//
+// Param is a parameter of a subprogram or cursor, a field of a record
+// or an attribute of an object type:
+//
+//	{
+//	  "name": string, "doc": string,
+//	  "mode": "in" | "out" | "in out", // omitted if not specified
+//	  "nocopy": bool, "type": string, "default": string,
+//	  "start": Position, "end": Position
+//	}
+//
+// Pragma:
+//
+//	{"name": string, "args": [string], "start": Position, "end": Position}
+//
+// Type is a collection, record or ref cursor type:
+//
+//	{
+//	  "name": string, "doc": string,
+//	  "kind": "table" | "varray" | "record" | "ref cursor",
+//	  "type": string,             // element type or ref cursor's return type
+//	  "fields": [Param],          // record fields
+//	  "start": Position, "end": Position
+//	}
+//
+// Subtype:
+//
+//	{
+//	  "name": string, "doc": string,
+//	  "base": string, "range": string, "notNull": bool,
+//	  "start": Position, "end": Position
+//	}
+//
+// Cursor:
+//
+//	{
+//	  "name": string, "doc": string,
+//	  "params": [Param], "returns": string, "sql": string,
+//	  "start": Position, "end": Position
+//	}
+//
+// ObjectType is a type created with "create type ... as object":
+//
+//	{
+//	  "name": string, "doc": string,
+//	  "under": string,            // supertype
+//	  "final": bool, "instantiable": bool,
+//	  "attributes": [Param],
+//	  "methods": [Method],
+//	  "start": Position, "end": Position
+//	}
+//
+// Method has all fields of Subprogram and also:
+//
+//	{
+//	  "methodKind": "member" | "static" | "constructor",
+//	  "map": bool, "order": bool, "overriding": bool,
+//	  "final": bool, "instantiable": bool
+//	}
+package jsondoc
+
+import (
+	"encoding/json"
+	"github.com/cyevgeniy/pldoc/ast"
+	"github.com/cyevgeniy/pldoc/token"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Version of the JSON schema
+const Version = 1
+
+// Name of the file that Execute writes
+const FileName = "pldoc.json"
+
+type jsonDoc struct {
+	Version int         `json:"version"`
+	Files   []*jsonFile `json:"files"`
+}
+
+type jsonFile struct {
+	Path        string            `json:"path"`
+	Packages    []*jsonPackage    `json:"packages,omitempty"`
+	Types       []*jsonObjectType `json:"types,omitempty"`
+	Subprograms []*jsonSubprogram `json:"subprograms,omitempty"`
+}
+
+type jsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type jsonPackage struct {
+	Name         string            `json:"name"`
+	Schema       string            `json:"schema,omitempty"`
+	Doc          string            `json:"doc,omitempty"`
+	AuthID       string            `json:"authid,omitempty"`
+	Edition      string            `json:"edition,omitempty"`
+	Sharing      string            `json:"sharing,omitempty"`
+	AccessibleBy []string          `json:"accessibleBy,omitempty"`
+	Pragmas      []*jsonPragma     `json:"pragmas,omitempty"`
+	Variables    []*jsonVariable   `json:"variables,omitempty"`
+	Subprograms  []*jsonSubprogram `json:"subprograms,omitempty"`
+	Types        []*jsonType       `json:"types,omitempty"`
+	Subtypes     []*jsonSubtype    `json:"subtypes,omitempty"`
+	Cursors      []*jsonCursor     `json:"cursors,omitempty"`
+	Start        jsonPosition      `json:"start"`
+	End          jsonPosition      `json:"end"`
+}
+
+type jsonVariable struct {
+	Name      string       `json:"name"`
+	Doc       string       `json:"doc,omitempty"`
+	Kind      string       `json:"kind"`
+	Type      string       `json:"type,omitempty"`
+	Default   string       `json:"default,omitempty"`
+	NotNull   bool         `json:"notNull,omitempty"`
+	ErrorCode string       `json:"errorCode,omitempty"`
+	Start     jsonPosition `json:"start"`
+	End       jsonPosition `json:"end"`
+}
+
+type jsonParam struct {
+	Name    string       `json:"name"`
+	Doc     string       `json:"doc,omitempty"`
+	Mode    string       `json:"mode,omitempty"`
+	NoCopy  bool         `json:"nocopy,omitempty"`
+	Type    string       `json:"type,omitempty"`
+	Default string       `json:"default,omitempty"`
+	Start   jsonPosition `json:"start"`
+	End     jsonPosition `json:"end"`
+}
+
+type jsonPragma struct {
+	Name  string       `json:"name"`
+	Args  []string     `json:"args,omitempty"`
+	Start jsonPosition `json:"start"`
+	End   jsonPosition `json:"end"`
+}
+
+type jsonSubprogram struct {
+	Name           string        `json:"name"`
+	Doc            string        `json:"doc,omitempty"`
+	Kind           string        `json:"kind"`
+	Params         []*jsonParam  `json:"params,omitempty"`
+	Returns        string        `json:"returns,omitempty"`
+	Deterministic  bool          `json:"deterministic,omitempty"`
+	Pipelined      bool          `json:"pipelined,omitempty"`
+	PipelinedUsing string        `json:"pipelinedUsing,omitempty"`
+	ResultCache    bool          `json:"resultCache,omitempty"`
+	ReliesOn       []string      `json:"reliesOn,omitempty"`
+	ParallelEnable bool          `json:"parallelEnable,omitempty"`
+	Partition      string        `json:"partition,omitempty"`
+	AggregateUsing string        `json:"aggregateUsing,omitempty"`
+	SQLMacro       string        `json:"sqlMacro,omitempty"`
+	AccessibleBy   []string      `json:"accessibleBy,omitempty"`
+	Pragmas        []*jsonPragma `json:"pragmas,omitempty"`
+	Start          jsonPosition  `json:"start"`
+	End            jsonPosition  `json:"end"`
+}
+
+type jsonType struct {
+	Name   string       `json:"name"`
+	Doc    string       `json:"doc,omitempty"`
+	Kind   string       `json:"kind"`
+	Type   string       `json:"type,omitempty"`
+	Fields []*jsonParam `json:"fields,omitempty"`
+	Start  jsonPosition `json:"start"`
+	End    jsonPosition `json:"end"`
+}
+
+type jsonSubtype struct {
+	Name    string       `json:"name"`
+	Doc     string       `json:"doc,omitempty"`
+	Base    string       `json:"base"`
+	Range   string       `json:"range,omitempty"`
+	NotNull bool         `json:"notNull,omitempty"`
+	Start   jsonPosition `json:"start"`
+	End     jsonPosition `json:"end"`
+}
+
+type jsonCursor struct {
+	Name    string       `json:"name"`
+	Doc     string       `json:"doc,omitempty"`
+	Params  []*jsonParam `json:"params,omitempty"`
+	Returns string       `json:"returns,omitempty"`
+	SQL     string       `json:"sql,omitempty"`
+	Start   jsonPosition `json:"start"`
+	End     jsonPosition `json:"end"`
+}
+
+type jsonMethod struct {
+	*jsonSubprogram
+	MethodKind   string `json:"methodKind"`
+	Map          bool   `json:"map,omitempty"`
+	Order        bool   `json:"order,omitempty"`
+	Overriding   bool   `json:"overriding,omitempty"`
+	Final        bool   `json:"final"`
+	Instantiable bool   `json:"instantiable"`
+}
+
+type jsonObjectType struct {
+	Name         string        `json:"name"`
+	Doc          string        `json:"doc,omitempty"`
+	Under        string        `json:"under,omitempty"`
+	Final        bool          `json:"final"`
+	Instantiable bool          `json:"instantiable"`
+	Attributes   []*jsonParam  `json:"attributes,omitempty"`
+	Methods      []*jsonMethod `json:"methods,omitempty"`
+	Start        jsonPosition  `json:"start"`
+	End          jsonPosition  `json:"end"`
+}
+
+// Converts AST nodes to their JSON representation
+type converter struct {
+	fset *token.FileSet
+}
+
+func (c *converter) pos(p token.Pos) jsonPosition {
+	pos := c.fset.Position(p)
+	return jsonPosition{Line: pos.Line, Column: pos.Column}
+}
+
+func identNames(list []*ast.Ident) []string {
+	var res []string
+	for i := range list {
+		res = append(res, list[i].Name)
+	}
+
+	return res
+}
+
+func accessors(list []*ast.Accessor) []string {
+	var res []string
+	for i := range list {
+		res = append(res, list[i].String())
+	}
+
+	return res
+}
+
+func varKind(kind ast.VarType) string {
+	switch kind {
+	case ast.VConst:
+		return "constant"
+	case ast.VExc:
+		return "exception"
+	}
+
+	return "variable"
+}
+
+func typeKind(kind ast.TypeKind) string {
+	switch kind {
+	case ast.TkTable:
+		return "table"
+	case ast.TkVarray:
+		return "varray"
+	case ast.TkRecord:
+		return "record"
+	case ast.TkRefCursor:
+		return "ref cursor"
+	}
+
+	return ""
+}
+
+func (c *converter) pragmas(list []*ast.Pragma) []*jsonPragma {
+	var res []*jsonPragma
+	for _, pr := range list {
+		res = append(res, &jsonPragma{
+			Name:  pr.Name.Name,
+			Args:  identNames(pr.Args),
+			Start: c.pos(pr.Start()),
+			End:   c.pos(pr.End()),
+		})
+	}
+
+	return res
+}
+
+func (c *converter) variable(f *ast.Field) *jsonVariable {
+	res := &jsonVariable{
+		Name:      f.Name.Name,
+		Doc:       f.Doc.Text(),
+		Kind:      varKind(f.Kind),
+		Default:   f.Def.String(),
+		NotNull:   f.Null,
+		ErrorCode: f.Code.String(),
+		Start:     c.pos(f.Start()),
+		End:       c.pos(f.End()),
+	}
+
+	// The type of an exception is the exception keyword
+	if f.Kind != ast.VExc {
+		res.Type = f.T.String()
+	}
+
+	return res
+}
+
+func (c *converter) params(fl *ast.FieldList) []*jsonParam {
+	if fl == nil {
+		return nil
+	}
+
+	return c.fields(fl.List)
+}
+
+func (c *converter) fields(list []*ast.Field) []*jsonParam {
+	var res []*jsonParam
+	for _, f := range list {
+		res = append(res, &jsonParam{
+			Name:    f.Name.Name,
+			Doc:     f.Doc.Text(),
+			Mode:    f.Mod.String(),
+			NoCopy:  f.NoCopy,
+			Type:    f.T.String(),
+			Default: f.Def.String(),
+			Start:   c.pos(f.Start()),
+			End:     c.pos(f.End()),
+		})
+	}
+
+	return res
+}
+
+func (c *converter) subprogram(fs *ast.FuncSpec) *jsonSubprogram {
+	res := &jsonSubprogram{
+		Name:           fs.Name.Name,
+		Doc:            fs.Doc.Text(),
+		Kind:           "procedure",
+		Params:         c.params(fs.Params),
+		PipelinedUsing: fs.PipelinedUsing.String(),
+		Partition:      fs.Partition.String(),
+		AggregateUsing: fs.AggregateUsing.String(),
+		SQLMacro:       fs.SQLMacro.String(),
+		AccessibleBy:   accessors(fs.AccessibleBy),
+		Pragmas:        c.pragmas(fs.Pragmas),
+		Start:          c.pos(fs.Start()),
+		End:            c.pos(fs.End()),
+	}
+
+	// Function-only options are ignored for procedures
+	if fs.Ftype == ast.FtFunc {
+		res.Kind = "function"
+		res.Returns = fs.T.String()
+		res.Deterministic = fs.Deterministic
+		res.Pipelined = fs.Pipelined
+		res.ResultCache = fs.ResultCache
+		res.ReliesOn = identNames(fs.ReliesOn)
+		res.ParallelEnable = fs.ParallelEnable
+	}
+
+	return res
+}
+
+func (c *converter) typeDecl(td *ast.TypeDecl) *jsonType {
+	return &jsonType{
+		Name:   td.Name.Name,
+		Doc:    td.Doc.Text(),
+		Kind:   typeKind(td.Kind),
+		Type:   td.T.String(),
+		Fields: c.params(td.Params),
+		Start:  c.pos(td.Start()),
+		End:    c.pos(td.End()),
+	}
+}
+
+func (c *converter) subtype(sd *ast.SubtypeDecl) *jsonSubtype {
+	return &jsonSubtype{
+		Name:    sd.Name.Name,
+		Doc:     sd.Doc.Text(),
+		Base:    sd.Base.String(),
+		Range:   sd.Range.String(),
+		NotNull: sd.NotNull,
+		Start:   c.pos(sd.Start()),
+		End:     c.pos(sd.End()),
+	}
+}
+
+func (c *converter) cursor(cd *ast.CursorDecl) *jsonCursor {
+	res := &jsonCursor{
+		Name:    cd.Name.Name,
+		Doc:     cd.Doc.Text(),
+		Params:  c.params(cd.Params),
+		Returns: cd.T.String(),
+		Start:   c.pos(cd.Start()),
+		End:     c.pos(cd.End()),
+	}
+
+	if cd.SQL != nil {
+		res.SQL = cd.SQL.Text
+	}
+
+	return res
+}
+
+func (c *converter) pck(pck *ast.Package) *jsonPackage {
+	res := &jsonPackage{
+		Name:         pck.Name.Name,
+		Schema:       pck.Schema.String(),
+		Doc:          pck.Doc.Text(),
+		AuthID:       pck.AuthID.String(),
+		Edition:      pck.Edition.String(),
+		Sharing:      pck.Sharing.String(),
+		AccessibleBy: accessors(pck.AccessibleBy),
+		Pragmas:      c.pragmas(pck.Pragmas),
+		Start:        c.pos(pck.Start()),
+		End:          c.pos(pck.End()),
+	}
+
+	for _, vd := range pck.VarDecls {
+		res.Variables = append(res.Variables, c.variable(vd))
+	}
+
+	for _, fs := range pck.FuncSpecs {
+		res.Subprograms = append(res.Subprograms, c.subprogram(fs))
+	}
+
+	for _, td := range pck.TypeDecls {
+		res.Types = append(res.Types, c.typeDecl(td))
+	}
+
+	for _, sd := range pck.SubtypeDecls {
+		res.Subtypes = append(res.Subtypes, c.subtype(sd))
+	}
+
+	for _, cd := range pck.CursorDecls {
+		res.Cursors = append(res.Cursors, c.cursor(cd))
+	}
+
+	return res
+}
+
+func (c *converter) objectType(t *ast.ObjectType) *jsonObjectType {
+	res := &jsonObjectType{
+		Name:         t.Name.Name,
+		Doc:          t.Doc.Text(),
+		Under:        t.Super.String(),
+		Final:        t.Final,
+		Instantiable: t.Instantiable,
+		Start:        c.pos(t.Start()),
+		End:          c.pos(t.End()),
+	}
+
+	res.Attributes = c.fields(t.Attrs)
+
+	for _, m := range t.Methods {
+		sp := c.subprogram(m.Spec)
+		sp.Start = c.pos(m.Start())
+
+		res.Methods = append(res.Methods, &jsonMethod{
+			jsonSubprogram: sp,
+			MethodKind:     m.Kind.String(),
+			Map:            m.Map,
+			Order:          m.Order,
+			Overriding:     m.Overriding,
+			Final:          m.Final,
+			Instantiable:   m.Instantiable,
+		})
+	}
+
+	return res
+}
+
+// Write writes the documentation of all files as JSON into w.
+// paths maps file names to the paths that are written in the
+// output; names that aren't in the map are written as is.
+func Write(w io.Writer, f *ast.Files, paths map[string]string) error {
+	c := &converter{fset: f.FileSet}
+	doc := &jsonDoc{Version: Version, Files: []*jsonFile{}}
+
+	for _, file := range f.Files {
+		jf := &jsonFile{Path: file.Name}
+		if path, ok := paths[file.Name]; ok {
+			jf.Path = filepath.ToSlash(path)
+		}
+
+		for _, pck := range file.Packages {
+			jf.Packages = append(jf.Packages, c.pck(pck))
+		}
+
+		for _, t := range file.Types {
+			jf.Types = append(jf.Types, c.objectType(t))
+		}
+
+		for _, fs := range file.Funcs {
+			jf.Subprograms = append(jf.Subprograms, c.subprogram(fs))
+		}
+
+		doc.Files = append(doc.Files, jf)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(doc)
+}
+
+// Execute writes the documentation into the FileName file
+// in the dir directory. The directory is created if it
+// doesn't exist.
+func Execute(dir string, f *ast.Files, paths map[string]string) error {
+	err := os.Mkdir(dir, 0750)
+	if err != nil && !os.IsExist(err) {
+		return err
+	}
+
+	out, err := os.Create(filepath.Join(dir, FileName))
+	if err != nil {
+		return err
+	}
+
+	err = Write(out, f, paths)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsondoc
+
+import (
+	"bytes"
+	"flag"
+	"github.com/cyevgeniy/pldoc/ast"
+	"github.com/cyevgeniy/pldoc/parser"
+	"github.com/cyevgeniy/pldoc/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update .golden files")
+
+// Each testdata/*.pks file is written as JSON and compared
+// with the testdata/*.golden file
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.pks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fname := range files {
+		src, err := os.ReadFile(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fset := &ast.Files{FileSet: token.NewFileSet()}
+		f, err := parser.ParseFile(fset.FileSet, fname, src)
+		if err != nil {
+			t.Errorf("%s: %v", fname, err)
+			continue
+		}
+		fset.Add(f)
+
+		var buf bytes.Buffer
+		err = Write(&buf, fset, map[string]string{fname: filepath.Base(fname)})
+		if err != nil {
+			t.Errorf("%s: %v", fname, err)
+			continue
+		}
+
+		golden := strings.TrimSuffix(fname, ".pks") + ".golden"
+		if *update {
+			if err := os.WriteFile(golden, buf.Bytes(), 0666); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%s: output differs from %s, run go test -update and check the diff", fname, golden)
+		}
+	}
+}
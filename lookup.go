@@ -0,0 +1,65 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/cyevgeniy/pldoc/scanner"
+	"github.com/cyevgeniy/pldoc/template"
+	"log"
+	"os"
+	"runtime"
+)
+
+// Runs the doc command, which prints the docs of a declaration:
+//
+//	pldoc doc [-all] [-ext=pks] [name] [source_directory...]
+//
+// Without a name, it lists packages, types and standalone subprograms.
+// Directories default to the current one.
+func docMain(args []string) {
+	flags := flag.NewFlagSet("doc", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: pldoc doc [flags] [name] [source_directory...]")
+		fmt.Fprintln(flags.Output(), "Names are like pck, pck.func, schema.pck.func, obj_type or obj_type.method.")
+		flags.PrintDefaults()
+	}
+
+	var ext = flags.String("ext", "pks", "The extension of specification files")
+	var all = flags.Bool("all", false, "Show the docs of all declarations of the package or the type")
+	var jobs = flags.Int("j", runtime.NumCPU(), "The number of files parsed in parallel")
+
+	flags.Parse(args)
+
+	var name string
+	dirs := []string{"."}
+	if flags.NArg() > 0 {
+		name = flags.Arg(0)
+	}
+	if flags.NArg() > 1 {
+		dirs = flags.Args()[1:]
+	}
+
+	files, _, err := findFiles(dirs, *ext)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fset, err := genFileSet("Documentation", files, *jobs)
+	if err != nil {
+		if _, ok := err.(scanner.ErrorList); !ok {
+			log.Fatal(err)
+		}
+
+		// Declarations after syntax errors may be missing,
+		// so the errors are reported with the lookup's result
+		scanner.PrintError(os.Stderr, err)
+	}
+
+	if err = template.WriteDoc(os.Stdout, fset, name, *all); err != nil {
+		log.Fatal(err)
+	}
+}
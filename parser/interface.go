@@ -6,16 +6,45 @@
 package parser
 
 import (
+	"crypto/sha256"
+	"errors"
 	"github.com/cyevgeniy/pldoc/ast"
+	"github.com/cyevgeniy/pldoc/token"
 )
 
-func ParseFile(fname string, src []byte) (f *ast.File) {
+// ParseFile parses the source code of a single PL/SQL source file
+// and returns the corresponding ast.File node. Positions of the nodes
+// are recorded in fset, which must not be nil. The file is added
+// to fset even if it has errors.
+//
+// If the source couldn't be read completely, the returned AST
+// contains everything that was parsed successfully, and the error
+// is a scanner.ErrorList with all found errors, sorted by
+// source position.
+func ParseFile(fset *token.FileSet, fname string, src []byte) (f *ast.File, err error) {
 	if fname == "" {
-		panic("Empty file provided")
+		return nil, errors.New("empty file name provided")
 	}
 
 	var p Parser
-	p.Init(fname, src, false)
+	defer func() {
+		if e := recover(); e != nil {
+			// resume same panic if it's not a bailout
+			if _, ok := e.(bailout); !ok {
+				panic(e)
+			}
+		}
+
+		if f == nil {
+			f = &ast.File{Name: fname, FileStart: p.file.Pos(0)}
+		}
+		f.Sum = sha256.Sum256(src)
+
+		p.errors.Sort()
+		err = p.errors.Err()
+	}()
+
+	p.Init(fset, fname, src, false)
 
 	f = p.parseFile()
 
@@ -22,18 +22,27 @@ type Parser struct {
 	tok token.Token
 	lit string
 
+	// Position right after the current token and
+	// after the previous non-comment token. Used to
+	// set the end positions of nodes.
+	tokEnd  token.Pos
+	prevEnd token.Pos
+
 	comments    []*ast.CommentGroup
 	leadComment *ast.CommentGroup
 	lineComment *ast.CommentGroup
 
 	// For Cursor SQL query's text.
 	src []byte
+
+	errors scanner.ErrorList
 }
 
-func (p *Parser) Init(fname string, src []byte, trace bool) {
-	p.file = token.NewFile(fname)
+func (p *Parser) Init(fset *token.FileSet, fname string, src []byte, trace bool) {
+	p.file = fset.AddFile(fname, -1, len(src))
 	p.trace = trace
-	p.scanner.Init(p.file, src)
+	eh := func(pos token.Position, msg string) { p.errors.Add(pos, msg) }
+	p.scanner.Init(p.file, src, eh)
 	p.pos = token.NoPos
 	p.src = src
 	p.next()
@@ -45,6 +54,7 @@ func (p *Parser) next0() {
 		fmt.Printf("Token=%s, Literal=%s, Line: %d\n", p.tok, p.lit, p.file.Line(p.pos))
 	}
 	p.pos, p.tok, p.lit = p.scanner.Scan()
+	p.tokEnd = p.file.Pos(p.scanner.Offset())
 }
 
 // Consume a comment and return it and the line on which it ends.
@@ -105,6 +115,7 @@ func (p *Parser) next() {
 	p.leadComment = nil
 	p.lineComment = nil
 	prev := p.pos
+	p.prevEnd = p.tokEnd
 	p.next0()
 
 	if p.tok == token.COMMENT {
@@ -147,158 +158,588 @@ func (p *Parser) scanTo(tok token.Token) {
 }
 
 func (p *Parser) parseFile() *ast.File {
-	pck := p.parsePackages()
+	f := &ast.File{
+		Name:      p.file.Filename,
+		FileStart: p.file.Pos(0),
+	}
 
-	return &ast.File{
-		Name:     p.file.Filename,
-		Packages: pck,
+	for p.tok != token.EOF {
+		switch unit := p.tryParseUnit().(type) {
+		case *ast.Package:
+			f.Packages = append(f.Packages, unit)
+		case *ast.ObjectType:
+			f.Types = append(f.Types, unit)
+		case *ast.FuncSpec:
+			f.Funcs = append(f.Funcs, unit)
+		}
 	}
+
+	return f
 }
 
-func (p *Parser) parsePackages() []*ast.Package {
-	var pcks []*ast.Package
+// Parses a unit like parseUnit does, but if the unit
+// can't be parsed, returns nil instead of aborting the whole file.
+// The error is already recorded at this point, and the
+// next unit is searched from the current position.
+func (p *Parser) tryParseUnit() (unit ast.Node) {
+	defer func() {
+		if e := recover(); e != nil {
+			if _, ok := e.(bailout); !ok {
+				panic(e)
+			}
+			unit = nil
+		}
+	}()
+
+	return p.parseUnit()
+}
 
-	for p.tok != token.EOF {
-		if pck := p.parsePackage(); pck != nil {
-			pcks = append(pcks, pck)
+// Parses the next schema-level unit that starts with the
+// CREATE statement: a package, an object type or a standalone
+// subprogram. Returns nil if there are no more units or if
+// the unit isn't documented (package and type bodies, tables etc).
+//
+// Units that aren't documented are skipped by searching for
+// the next CREATE statement: it can't appear inside a PL/SQL
+// block, so we don't need to parse bodies.
+func (p *Parser) parseUnit() ast.Node {
+	p.scanTo(token.CREATE)
+	if p.tok != token.CREATE {
+		return nil
+	}
+
+	doc := p.leadComment
+	p.next()
+
+	// create [or replace] [editionable | noneditionable] ...
+	if p.tok == token.OR {
+		p.next()
+		p.next()
+	}
+
+	var edition *ast.Ident
+	if p.tok == token.IDENT && (p.lit == "editionable" || p.lit == "noneditionable") {
+		edition = &ast.Ident{Name: p.lit, First: p.pos}
+		p.next()
+	}
+
+	switch p.tok {
+	case token.PACKAGE:
+		if pck := p.parsePackage(doc); pck != nil {
+			pck.Edition = edition
+			return pck
+		}
+	case token.FUNCTION, token.PROCEDURE:
+		return p.parseStandaloneFunc(doc)
+	case token.TYPE:
+		if typ := p.parseObjectType(doc); typ != nil {
+			return typ
 		}
 	}
-	return pcks
+
+	return nil
 }
 
-func (p *Parser) skipPackageBody() {
-	// We are at the beginning of a package. It may
-	// be any position before first package's function, procedure or
-	// anything else that may contain the `begin` keyword.
-	var balance byte = 1
+func (p *Parser) parsePackage(doc *ast.CommentGroup) *ast.Package {
+	// We are at the PACKAGE token
+	pck := &ast.Package{
+		Doc:   doc,
+		First: p.pos,
+	}
 
-	// Skip until the package's `end` or EOF
-	for balance != 0 && p.tok != token.EOF {
-		p.next()
+	if !p.parsePackageHeader(pck) {
+		return nil
+	}
 
-		if p.tok == token.BEGIN {
-			balance += 1
-		} else if p.tok == token.END {
-			balance -= 1
+	pckNodes := p.parsePackageNodes(pck.Name.Name)
+
+	// We are at the package's name after END, if it's specified
+	pck.Last = p.prevEnd
+	if p.tok == token.IDENT {
+		pck.Last = p.tokEnd
+	}
+
+	var fSpecs []*ast.FuncSpec
+	var vDecls []*ast.Field
+	var sTypeDecls []*ast.SubtypeDecl
+	var cDecls []*ast.CursorDecl
+	var tDecls []*ast.TypeDecl
+	var pragmas []*ast.Pragma
+
+	for i := range pckNodes {
+		switch pckNodes[i].(type) {
+		case *ast.FuncSpec:
+			fSpecs = append(fSpecs, pckNodes[i].(*ast.FuncSpec))
+		case *ast.SubtypeDecl:
+			sTypeDecls = append(sTypeDecls, pckNodes[i].(*ast.SubtypeDecl))
+		case *ast.CursorDecl:
+			cDecls = append(cDecls, pckNodes[i].(*ast.CursorDecl))
+		case *ast.Field:
+			vDecls = append(vDecls, pckNodes[i].(*ast.Field))
+		case *ast.TypeDecl:
+			tDecls = append(tDecls, pckNodes[i].(*ast.TypeDecl))
+		case *ast.Pragma:
+			pragmas = append(pragmas, pckNodes[i].(*ast.Pragma))
 		}
 	}
 
-	return
+	pck.VarDecls = vDecls
+	pck.SubtypeDecls = sTypeDecls
+	pck.FuncSpecs = fSpecs
+	pck.CursorDecls = cDecls
+	pck.TypeDecls = tDecls
+	pck.Pragmas = pragmas
+
+	linkPragmas(pck)
+
+	return pck
 }
 
-func (p *Parser) parsePackage() *ast.Package {
-	var doc *ast.CommentGroup
+// Links pragmas of a package specification to the declarations
+// they refer to. Exception_init sets the error code of the exception,
+// other pragmas that name a subprogram are added to all its overloads.
+// Pragmas that refer to the package itself stay only in Package.Pragmas.
+func linkPragmas(pck *ast.Package) {
+	for _, pr := range pck.Pragmas {
+		target := pr.Target()
+
+		if pr.Name.Name == "exception_init" {
+			for _, vd := range pck.VarDecls {
+				if vd.Kind == ast.VExc && vd.Name.Name == target && len(pr.Args) > 1 {
+					vd.Code = pr.Args[1]
+				}
+			}
 
-	p.scanTo(token.CREATE)
-	if p.tok == token.CREATE {
-		doc = p.leadComment
+			continue
+		}
+
+		for _, fs := range pck.FuncSpecs {
+			if fs.Name.Name == target {
+				fs.Pragmas = append(fs.Pragmas, pr)
+			}
+		}
 	}
+}
 
-	p.scanTo(token.PACKAGE)
+// Parses the package's name and the clauses that precede
+// the IS or AS keyword:
+//
+//	package [if not exists] [schema.]name [sharing = {metadata | none}]
+//	    [default collation collation_name] [authid {current_user | definer}]
+//	    [accessible by (accessor, ...)] {is | as}
+//
+// Returns false if current position in the source is package's body,
+// but not its specification. When the function returns true, we are
+// at the IS or AS keyword.
+func (p *Parser) parsePackageHeader(pck *ast.Package) bool {
+	// we are at token "PACKAGE" now
+	p.next()
+	if p.tok == token.BODY {
+		return false
+	}
 
-	// We may be at EOF or at PACKAGE
-	if p.tok == token.PACKAGE {
+	if p.tok == token.IF {
+		// if not exists
+		p.next()
+		p.expect(token.NOT)
+		p.next()
+	}
 
-		pckName := p.parsePackageName()
-		if pckName == nil {
-			return nil
+	pck.Schema, pck.Name = p.parseObjectName()
+
+	for p.tok != token.IS && p.tok != token.AS {
+		switch {
+		case p.tok == token.EOF:
+			p.errorExpected(p.pos, "is or as")
+		case p.tok == token.AUTHID:
+			// current_user is a keyword
+			p.next()
+			if p.tok != token.IDENT && !token.IsKeyword(p.tok) {
+				p.errorExpected(p.pos, "current_user or definer")
+			}
+			pck.AuthID = &ast.Ident{Name: p.lit, First: p.pos}
+		case p.isWord("accessible"):
+			p.next()
+			p.expect(token.BY)
+			pck.AccessibleBy = p.parseAccessors()
+			continue
+		case p.tok == token.IDENT && p.lit == "sharing":
+			p.next()
+			p.expect(token.EQL)
+			pck.Sharing = &ast.Ident{Name: p.lit, First: p.pos}
 		}
 
-		pckNodes := p.parsePackageNodes(pckName.Name)
-
-		var fSpecs []*ast.FuncSpec
-		var vDecls []*ast.Field
-		var sTypeDecls []*ast.SubtypeDecl
-		var cDecls []*ast.CursorDecl
-		var tDecls []*ast.TypeDecl
-
-		for i := range pckNodes {
-			switch pckNodes[i].(type) {
-			case *ast.FuncSpec:
-				fSpecs = append(fSpecs, pckNodes[i].(*ast.FuncSpec))
-			case *ast.SubtypeDecl:
-				sTypeDecls = append(sTypeDecls, pckNodes[i].(*ast.SubtypeDecl))
-			case *ast.CursorDecl:
-				cDecls = append(cDecls, pckNodes[i].(*ast.CursorDecl))
-			case *ast.Field:
-				vDecls = append(vDecls, pckNodes[i].(*ast.Field))
-			case *ast.TypeDecl:
-				tDecls = append(tDecls, pckNodes[i].(*ast.TypeDecl))
-			}
-		}
-
-		return &ast.Package{
-			Doc:          doc,
-			First:        token.Pos(0),
-			Last:         token.Pos(0),
-			Name:         pckName,
-			VarDecls:     vDecls,
-			SubtypeDecls: sTypeDecls,
-			FuncSpecs:    fSpecs,
-			CursorDecls:  cDecls,
-			TypeDecls:    tDecls,
+		// Clauses we don't document, like default collation,
+		// are skipped
+		p.next()
+	}
+
+	return true
+}
+
+// Parses a standalone function or procedure. The body
+// isn't documented and is skipped.
+func (p *Parser) parseStandaloneFunc(doc *ast.CommentGroup) *ast.FuncSpec {
+	fSpec := p.parseFuncHeader()
+	fSpec.Doc = doc
+
+	// Skip remaining clauses (authid, accessible by etc.)
+	for p.tok != token.IS && p.tok != token.AS {
+		if p.tok == token.EOF || p.tok == token.SEMICOLON {
+			p.errorExpected(p.pos, "is or as")
+		}
+		p.next()
+	}
+
+	// Pragmas of the declarative part, like autonomous_transaction,
+	// refer to the subprogram itself, unless they name something else
+	for _, pr := range p.skipBody() {
+		if target := pr.Target(); target == "" || target == fSpec.Name.Name {
+			fSpec.Pragmas = append(fSpec.Pragmas, pr)
+		}
+	}
+
+	return fSpec
+}
+
+// Skips the body of a subprogram, a package or a type. We are at
+// the IS or AS keyword that starts the body. When the function
+// returns, we are at the semicolon after the body's final END.
+// Returns pragmas from the body's declarative part, except the ones
+// of nested subprograms.
+func (p *Parser) skipBody() (pragmas []*ast.Pragma) {
+	p.next()
+
+	// Call specifications, like "as language java name '...'",
+	// don't have a body
+	if p.tok == token.IDENT && (p.lit == "language" || p.lit == "external") {
+		p.scanTo(token.SEMICOLON)
+		return
+	}
+
+	// Declarative part. Nested subprograms are skipped recursively,
+	// so their BEGIN and END keywords aren't confused with ours.
+	for p.tok != token.EOF && p.tok != token.BEGIN && p.tok != token.END {
+		switch p.tok {
+		case token.DOLLAR:
+			// $if, $end and other conditional compilation
+			// keywords are skipped along with the dollar sign
+			p.next()
+		case token.PRAGMA:
+			pragmas = append(pragmas, p.parsePragma())
+		case token.FUNCTION, token.PROCEDURE:
+			for p.tok != token.EOF && p.tok != token.SEMICOLON && p.tok != token.IS && p.tok != token.AS {
+				p.next()
+			}
+
+			if p.tok == token.IS || p.tok == token.AS {
+				p.skipBody()
+			}
 		}
+
+		p.next()
+	}
+
+	if p.tok == token.BEGIN {
+		p.skipBlock()
 	} else {
-		return nil
+		// Package and type bodies may have
+		// no initialization part
+		p.scanTo(token.SEMICOLON)
 	}
 
+	return
 }
 
-// Function parsePackageName returns package name
-// identifier. If package name is specified with
-// schema (like "create or replace package sys.utl_pck as ..."),
-// the schema is ignored. It means that returned package name identifier
-// will be "utl_pck", not "sys.utl_pck". Skips package body and returns nil if
-// current position in the source is package's body, but not
-// its specification
-func (p *Parser) parsePackageName() *ast.Ident  {
-	// we are at token "PACKAGE" now
-	start := p.pos
+// Skips a block that starts with BEGIN up to the semicolon after
+// its END. Nested blocks and CASE statements and expressions are
+// closed with END too, so they are counted. END IF and END LOOP
+// close statements that don't affect the nesting.
+func (p *Parser) skipBlock() {
+	depth := 0
+
+	for p.tok != token.EOF {
+		switch p.tok {
+		case token.DOLLAR:
+			p.next()
+		case token.BEGIN, token.CASE:
+			depth++
+		case token.END:
+			p.next()
+			if p.tok == token.IF || p.tok == token.LOOP {
+				break
+			}
 
+			depth--
+			if depth == 0 {
+				p.scanTo(token.SEMICOLON)
+				return
+			}
+
+			// The token after END may start a new construct,
+			// unless it's the CASE of END CASE
+			if p.tok != token.CASE {
+				continue
+			}
+		}
+
+		p.next()
+	}
+}
+
+// Parses an object type specification:
+//
+//	create type [schema.]name [force] [authid ...] {is | as} object (...)
+//	    [not] final [not] instantiable;
+//	create type [schema.]name under [schema.]supertype (...) ...;
+//
+// Returns nil for type bodies, incomplete types and schema-level
+// collection types.
+func (p *Parser) parseObjectType(doc *ast.CommentGroup) *ast.ObjectType {
+	// We are at the TYPE token
+	first := p.pos
+
+	p.next()
 	if p.tok == token.BODY {
-		p.skipPackageBody()
 		return nil
 	}
 
-	packageName := ""
+	_, name := p.parseObjectName()
+
+	// Skip clauses before the type's definition (force, authid,
+	// accessible by, etc.)
+	for p.tok != token.IS && p.tok != token.AS && !p.isWord("under") {
+		if p.tok == token.EOF || p.tok == token.SEMICOLON || p.tok == token.DIV {
+			// Incomplete type: create type t;
+			return nil
+		}
+		p.next()
+	}
+
+	typ := &ast.ObjectType{
+		Doc:          doc,
+		First:        first,
+		Name:         name,
+		Final:        true,
+		Instantiable: true,
+	}
+
+	if p.isWord("under") {
+		p.next()
+		typ.Super = p.parseQualifiedName()
+	} else {
+		p.next()
+		if !p.isWord("object") {
+			// Schema-level collection types (create type t as table of ...)
+			// aren't documented yet.
+			return nil
+		}
+		p.next()
+	}
+
+	if p.tok == token.LPAREN {
+		p.parseObjectElems(typ)
+	}
+
+	// Type modifiers
+	not := false
+	for p.tok != token.EOF && p.tok != token.SEMICOLON && p.tok != token.DIV && p.tok != token.CREATE {
+		switch {
+		case p.tok == token.NOT:
+			not = true
+			p.next()
+			continue
+		case p.isWord("final"):
+			typ.Final = !not
+		case p.isWord("instantiable"):
+			typ.Instantiable = !not
+		}
+
+		not = false
+		p.next()
+	}
+
+	typ.Last = p.prevEnd
+
+	return typ
+}
+
+// Words that start a method specification of an object type.
+// They aren't reserved, so they are scanned as identifiers and
+// may name variables and parameters outside of object types.
+var methodWords = map[string]bool{
+	"overriding":   true,
+	"final":        true,
+	"instantiable": true,
+	"map":          true,
+	"order":        true,
+	"member":       true,
+	"static":       true,
+	"constructor":  true,
+}
+
+// Reports whether the current token is the non-reserved word,
+// which is scanned as an identifier
+func (p *Parser) isWord(word string) bool {
+	return p.tok == token.IDENT && p.lit == word
+}
+
+// Parses attributes and methods of an object type. We are at
+// the opening paren. When the function returns, we are at
+// the token that follows the closing paren.
+func (p *Parser) parseObjectElems(typ *ast.ObjectType) {
+	var pragmas []*ast.Pragma
 
-	// Scan package name and schema (if exists) and store in `packageName`.
-	//
-	// Examples:
-	// pck_name, Users.pck_name, "Users".pck_name, "Users"."pck_name"
-	//
-	// Then remove quotes and slice the part with the schema, leaving only
-	// a raw package name.
 	for {
 		p.next()
-		if p.tok != token.IS && p.tok != token.AS && p.tok != token.AUTHID {
 
-			// The last identifier between package ... [authid | as | is] is
-			// the package name, so update it each time we meet an identifier.
-			if p.tok == token.IDENT {
-				start = p.pos
-				packageName = p.lit
-			}
-		} else {
+		switch {
+		case p.tok == token.NOT || p.tok == token.IDENT && methodWords[p.lit]:
+			typ.Methods = append(typ.Methods, p.parseMethod())
+		case p.tok == token.PRAGMA:
+			pragmas = append(pragmas, p.parsePragma())
+		default:
+			typ.Attrs = append(typ.Attrs, p.parseParamDecl())
+		}
+
+		if p.tok == token.RPAREN {
 			break
 		}
+
+		// If we have not reached the final right paren,
+		// we expect comma, because there should be
+		// another one attribute or method
+		p.test(token.COMMA)
 	}
 
-	// Skip (current_user or definer) right to the 'AS' or 'IS',
-	// ignoring clauses that we can't handle (accessible by, for example
-	if p.tok == token.AUTHID {
-		for {
+	// Make progress
+	p.next()
+
+	for _, pr := range pragmas {
+		for _, m := range typ.Methods {
+			if m.Spec.Name.Name == pr.Target() {
+				m.Spec.Pragmas = append(m.Spec.Pragmas, pr)
+			}
+		}
+	}
+}
+
+// Parses a method specification of an object type:
+//
+//	[[not] overriding] [[not] final] [[not] instantiable]
+//	    [map | order] {member | static | constructor} {function | procedure} ...
+func (p *Parser) parseMethod() *ast.Method {
+	doc := p.leadComment
+
+	m := &ast.Method{
+		First:        p.pos,
+		Instantiable: true,
+	}
+
+	not := false
+
+loop:
+	for {
+		switch {
+		case p.tok == token.NOT:
+			not = true
 			p.next()
-			if p.tok == token.IS || p.tok == token.AS {
-				break
+			continue
+		case p.isWord("overriding"):
+			m.Overriding = !not
+		case p.isWord("final"):
+			m.Final = !not
+		case p.isWord("instantiable"):
+			m.Instantiable = !not
+		case p.isWord("map"):
+			m.Map = true
+		case p.isWord("order"):
+			m.Order = true
+		case p.isWord("member"):
+			m.Kind = ast.MkMember
+		case p.isWord("static"):
+			m.Kind = ast.MkStatic
+		case p.isWord("constructor"):
+			m.Kind = ast.MkConstructor
+		case p.tok == token.FUNCTION || p.tok == token.PROCEDURE:
+			break loop
+		default:
+			p.errorExpected(p.pos, "function or procedure")
+		}
+
+		not = false
+		p.next()
+	}
+
+	m.Spec = p.parseFuncHeader()
+	m.Spec.Doc = doc
+
+	// Skip method's options, if any
+	p.skipToListDelim()
+
+	return m
+}
+
+// Skips tokens up to the comma or the right paren that
+// delimits an element in a list. Nested parens are skipped.
+func (p *Parser) skipToListDelim() {
+	balance := 0
+
+	for p.tok != token.EOF {
+		switch p.tok {
+		case token.LPAREN:
+			balance++
+		case token.RPAREN:
+			if balance == 0 {
+				return
+			}
+			balance--
+		case token.COMMA:
+			if balance == 0 {
+				return
 			}
 		}
+
+		p.next()
 	}
+}
+
+// Parses the name of a schema object, which may be qualified
+// with the schema and enclosed in double quotes, like
+// "Users".pck_name. We are at the first token of the name.
+// When the function returns, we are at the token that follows the name.
+func (p *Parser) parseObjectName() (schema *ast.Ident, name *ast.Ident) {
+	name = p.parseQuotedIdent()
 
-	return &ast.Ident{
-		Name: packageName,
-		First: start,
+	if p.tok == token.DOT {
+		p.next()
+		schema = name
+		name = p.parseQuotedIdent()
 	}
+
+	return
+}
+
+// Parses an identifier that may be enclosed in double quotes.
+// Any keyword is a valid quoted identifier.
+func (p *Parser) parseQuotedIdent() *ast.Ident {
+	if p.tok != token.DQUOTE {
+		ident := p.genIdent()
+		p.next()
+		return ident
+	}
+
+	p.next()
+	if p.tok != token.IDENT && !token.IsKeyword(p.tok) {
+		p.errorExpected(p.pos, "identifier")
+	}
+
+	ident := &ast.Ident{Name: p.lit, First: p.pos}
+
+	p.next()
+	p.expect(token.DQUOTE)
+
+	return ident
 }
 
 func (p *Parser) expect(tok token.Token) token.Pos {
@@ -314,53 +755,157 @@ func (p *Parser) parsePackageNodes(pckName string) []ast.Node {
 
 	var res []ast.Node
 
-	for p.tok != token.EOF {
-		p.next()
+	// Move from the IS or AS keyword to the first declaration
+	p.next()
 
-		if p.tok == token.DOLLAR {
-			// skip any conditional compilation statements and
-			p.skipCond()
-			continue
+	for p.tok != token.EOF && p.tok != token.END {
+		if node := p.parseDecl(); node != nil {
+			res = append(res, node)
 		}
+	}
 
-		if p.tok == token.IDENT {
-			v := p.parseField()
-			res = append(res, v)
-		}
+	if p.tok == token.END {
+		p.next()
 
-		if p.tok == token.CURSOR {
-			doc := p.leadComment
-			c := p.parseCursor()
-			c.Doc = doc
-			res = append(res, c)
+		if p.tok == token.IDENT && p.lit != pckName {
+			p.error(p.pos, "incorrect package name, expected "+pckName)
 		}
+	}
 
-		if p.tok == token.FUNCTION || p.tok == token.PROCEDURE {
-			fSpec := p.parseFuncSpec()
-			res = append(res, fSpec)
-		}
+	return res
+}
 
-		if p.tok == token.PRAGMA {
-			// skip type declarations and pragma section for now
-			p.scanTo(token.SEMICOLON)
-		}
+// Parses a single declaration in a package specification and
+// moves to the first token of the next declaration. Returns nil
+// for the constructs that aren't documented, like conditional
+// compilation statements.
+//
+// If the declaration can't be parsed, the error is recorded,
+// the rest of the declaration is skipped and nil is returned.
+func (p *Parser) parseDecl() (node ast.Node) {
+	start := p.pos
 
-		if p.tok == token.TYPE {
-			typ := p.parseType()
-			res = append(res, typ)
+	defer func() {
+		if e := recover(); e != nil {
+			if _, ok := e.(bailout); !ok {
+				panic(e)
+			}
+			node = nil
+			p.syncDecl(start)
 		}
+	}()
+
+	switch p.tok {
+	case token.DOLLAR:
+		// skip any conditional compilation statements
+		p.skipCond()
+	case token.IDENT:
+		node = p.parseField()
+	case token.CURSOR:
+		doc := p.leadComment
+		c := p.parseCursor()
+		c.Doc = doc
+		node = c
+	case token.FUNCTION, token.PROCEDURE:
+		node = p.parseFuncSpec()
+	case token.PRAGMA:
+		node = p.parsePragma()
+		p.test(token.SEMICOLON)
+	case token.TYPE:
+		node = p.parseType()
+	case token.SUBTYPE:
+		node = p.parseSubtype()
+	}
+
+	p.next()
 
-		if p.tok == token.END {
+	return
+}
+
+// Parses a pragma:
+//
+//	pragma name [(argument, ...)]
+//
+// Arguments are kept as they are written in the source. We are at
+// the PRAGMA token. When the function returns, we are at the token
+// that follows the pragma.
+func (p *Parser) parsePragma() *ast.Pragma {
+	pr := &ast.Pragma{
+		Doc:   p.leadComment,
+		First: p.pos,
+	}
+	pr.Name = p.parseIdent()
+
+	p.next()
+
+	if p.tok == token.LPAREN {
+		for p.tok != token.RPAREN {
 			p.next()
 
-			if p.tok == token.IDENT && p.lit != pckName {
-				p.panic("Incorrect package name! Expecting " + pckName)
+			arg := &ast.Ident{First: p.pos}
+			prev := token.LPAREN
+			balance := 0
+
+			for p.tok != token.EOF && (balance > 0 || p.tok != token.COMMA && p.tok != token.RPAREN) {
+				if p.tok == token.LPAREN {
+					balance++
+				} else if p.tok == token.RPAREN {
+					balance--
+				}
+
+				arg.Name = p.appendLit(arg.Name, prev)
+				prev = p.tok
+				p.next()
 			}
-			break
+
+			if p.tok == token.EOF {
+				p.errorExpected(p.pos, "')'")
+			}
+
+			pr.Args = append(pr.Args, arg)
 		}
+
+		p.next()
 	}
 
-	return res
+	pr.Last = p.prevEnd
+
+	return pr
+}
+
+// Skips the rest of a declaration that started at the position start
+// and couldn't be parsed. The parser stops after the next semicolon
+// or at the next keyword that may start a declaration, whichever
+// comes first. The skipped span is reported as an error.
+func (p *Parser) syncDecl(start token.Pos) {
+	// Make progress if the declaration is broken
+	// right at its first token
+	if p.pos == start {
+		p.next()
+	}
+
+	prev := token.EOF
+
+loop:
+	for p.tok != token.EOF {
+		switch p.tok {
+		case token.SEMICOLON:
+			p.next()
+			break loop
+		case token.FUNCTION, token.PROCEDURE, token.CURSOR, token.SUBTYPE, token.END:
+			break loop
+		case token.TYPE:
+			// Don't stop at %type attributes
+			if prev != token.REM {
+				break loop
+			}
+		}
+
+		prev = p.tok
+		p.next()
+	}
+
+	p.error(start, fmt.Sprintf("declaration skipped up to line %d", p.file.Line(p.pos)))
 }
 
 func (p *Parser) skipCond() {
@@ -377,6 +922,8 @@ func (p *Parser) parseType() ast.Node {
 	var node ast.Node
 
 	doc := p.leadComment
+	first := p.pos
+
 	// Now we are at token.TYPE. Scan next token for
 	// type's name
 	p.next()
@@ -389,27 +936,90 @@ func (p *Parser) parseType() ast.Node {
 	p.next()
 
 	if p.tok == token.TABLE || p.tok == token.VARRAY {
-		ltype := p.parseListType()
-		ltype.Doc = doc
-		ltype.Name = name
-		node = ltype
+		node = p.parseListType()
 	} else if p.tok == token.RECORD {
-		ltype := p.parseRecordType()
-		ltype.Doc = doc
-		ltype.Name = name
-		node = ltype
+		node = p.parseRecordType()
 	} else if p.tok == token.REF {
-		ltype := p.parseRefCursorType()
-		ltype.Doc = doc
-		ltype.Name = name
-		node = ltype
+		node = p.parseRefCursorType()
 	} else {
 		// TODO: Check if there are any types that aren't parsed yet
 		//       For now, just jump to semicolon
 		p.scanTo(token.SEMICOLON)
+		return nil
 	}
 
-	return node
+	ltype := node.(*ast.TypeDecl)
+	ltype.Doc = doc
+	ltype.First = first
+	ltype.Last = p.prevEnd
+	ltype.Name = name
+
+	return ltype
+}
+
+// Parses a subtype declaration:
+//
+//	subtype name is base_type [range low .. high] [not null];
+//
+// Size and precision constraints, like number(10), are kept
+// as a part of the base type.
+func (p *Parser) parseSubtype() *ast.SubtypeDecl {
+	doc := p.leadComment
+	first := p.pos
+
+	// Now we are at token.SUBTYPE
+	p.next()
+	name := p.genIdent()
+
+	p.next()
+	p.expect(token.IS)
+
+	// "range" isn't a keyword, it may be used as an identifier
+	isRange := func() bool { return p.tok == token.IDENT && p.lit == "range" }
+
+	base := &ast.Ident{First: p.pos}
+	prev := token.EOF
+	for p.tok != token.EOF && p.tok != token.SEMICOLON && p.tok != token.NOT && !isRange() {
+		base.Name = p.appendLit(base.Name, prev)
+		prev = p.tok
+		p.next()
+	}
+
+	if base.Name == "" {
+		p.errorExpected(p.pos, "base type")
+	}
+	base.Last = p.prevEnd
+
+	var rng *ast.Ident
+	if isRange() {
+		p.next()
+		rng = &ast.Ident{First: p.pos}
+		for p.tok != token.EOF && p.tok != token.SEMICOLON && p.tok != token.NOT {
+			rng.Name += p.lit
+			p.next()
+		}
+		rng.Last = p.prevEnd
+	}
+
+	notNull := false
+	if p.tok == token.NOT {
+		p.next()
+		p.test(token.NULL)
+		notNull = true
+		p.next()
+	}
+
+	p.test(token.SEMICOLON)
+
+	return &ast.SubtypeDecl{
+		Doc:     doc,
+		First:   first,
+		Last:    p.prevEnd,
+		Name:    name,
+		Base:    base,
+		Range:   rng,
+		NotNull: notNull,
+	}
 }
 
 func (p *Parser) parseRefCursorType() *ast.TypeDecl {
@@ -442,6 +1052,7 @@ func (p *Parser) parseRefCursorType() *ast.TypeDecl {
 		typ = &ast.Ident{
 			Name:  typeLit,
 			First: start,
+			Last:  p.prevEnd,
 		}
 	}
 	return &ast.TypeDecl{
@@ -467,11 +1078,11 @@ func (p *Parser) parseListType() *ast.TypeDecl {
 
 	var tKind ast.TypeKind
 	var typeName string
-	start := token.Pos(int(p.pos) - len(p.lit))
+	var start token.Pos
 	if p.tok == token.TABLE {
-		tKind = ast.TkVarray
-	} else {
 		tKind = ast.TkTable
+	} else {
+		tKind = ast.TkVarray
 	}
 
 	// ignore varray size(like varray(40)) and
@@ -485,6 +1096,10 @@ func (p *Parser) parseListType() *ast.TypeDecl {
 	for {
 		p.next()
 
+		if !start.IsValid() {
+			start = p.pos
+		}
+
 		if p.tok != token.EOF && p.tok != token.SEMICOLON {
 			if token.IsKeyword(p.tok) {
 				if !prevKeyword {
@@ -506,11 +1121,12 @@ func (p *Parser) parseListType() *ast.TypeDecl {
 		// Doc and Name should be filled outside this function,
 		//
 		Kind: tKind,
-		T:    &ast.Ident{Name: typeName, First: start},
+		T:    &ast.Ident{Name: typeName, First: start, Last: p.prevEnd},
 	}
 }
 
 func (p *Parser) parseCursor() *ast.CursorDecl {
+	first := p.pos
 	name := p.parseIdent()
 
 	p.next()
@@ -522,7 +1138,7 @@ func (p *Parser) parseCursor() *ast.CursorDecl {
 	}
 
 	var t *ast.Ident
-	start := token.Pos(-1)
+	start := token.NoPos
 
 	if p.tok == token.RETURN {
 		t = p.parseCursorResult()
@@ -539,7 +1155,7 @@ func (p *Parser) parseCursor() *ast.CursorDecl {
 	for {
 		p.next()
 		if p.tok != token.SEMICOLON && p.tok != token.EOF {
-			if start == token.Pos(-1) {
+			if start == token.NoPos {
 				start = p.pos
 			}
 		} else {
@@ -547,12 +1163,20 @@ func (p *Parser) parseCursor() *ast.CursorDecl {
 		}
 	}
 
+	if start == token.NoPos {
+		// The cursor is declared without a query,
+		// like "cursor c is;", or the file ends after "is"
+		p.errorExpected(p.pos, "select statement")
+	}
+
 	sql.First = start
-	sql.Text = string(p.src[start:p.pos])
+	sql.Text = string(p.src[p.file.Offset(start):p.file.Offset(p.pos)])
 
 ret:
 
 	return &ast.CursorDecl{
+		First:  first,
+		Last:   p.prevEnd,
 		Name:   name,
 		Params: params,
 		T:      t,
@@ -571,60 +1195,91 @@ func (p *Parser) parseField() *ast.Field {
 		return &ast.Field{
 			Doc:  doc,
 			Name: name,
-			T:    &ast.Ident{First: token.Pos(int(p.pos) - len(p.lit)), Name: p.lit},
+			T:    &ast.Ident{First: p.pos, Name: p.lit},
 			Kind: ast.VExc,
 		}
 	}
 
 	vkind := ast.VVar
-	var typeName string
-	start := p.pos
 	if p.tok == token.CONSTANT {
 		vkind = ast.VConst
-		start = p.pos
-	} else {
-		typeName = p.lit
-	}
-
-	// For now, compose all before semicolon into the one big Ident
-	// In the real world, there can be many options that we need to
-	// parse
-	for {
 		p.next()
-		if p.tok != token.EOF && p.tok != token.SEMICOLON {
-			lit := p.lit
+	}
 
-			// String literals may appear in a field declaration
-			// as default values, like:
-			//     l_var constant varchar2(20) := 'hello';
-			// So, we wrap string literal with "'", because scanner
-			// removes quotes.
-			if p.tok == token.STRING {
-				lit = "'" + p.lit + "'"
+	// The declaration is split into the type, the NOT NULL
+	// constraint and the default value, which starts with
+	// := or DEFAULT, like:
+	//     c_name constant varchar2(20 char) not null := 'hello';
+	typ := &ast.Ident{First: p.pos}
+	var def *ast.Ident
+	var notNull bool
+	var prev token.Token
+	for ; p.tok != token.EOF && p.tok != token.SEMICOLON; p.next() {
+		switch {
+		case def != nil:
+			if def.Name == "" {
+				def.First = p.pos
+				prev = token.ILLEGAL
 			}
-			typeName = typeName + lit
-		} else {
-			break
+			def.Name = p.appendLit(def.Name, prev)
+		case p.tok == token.ASSIGN || p.tok == token.DEFAULT:
+			def = &ast.Ident{}
+		case p.tok == token.NOT || p.tok == token.NULL:
+			notNull = true
+		default:
+			typ.Name = p.appendLit(typ.Name, prev)
+			typ.Last = p.tokEnd
 		}
+
+		prev = p.tok
+	}
+
+	if def != nil {
+		def.Last = p.prevEnd
 	}
 
 	return &ast.Field{
 		Doc:  doc,
 		Name: name,
-		T:    &ast.Ident{Name: typeName, First: start},
+		T:    typ,
 		Kind: ast.VarType(vkind),
+		Def:  def,
+		Null: notNull,
 	}
 }
 
-func (p *Parser) panic(msg string) {
-	panic(fmt.Sprintf("File: %s; line: %d; %s", p.file.Filename,p.file.Line(p.pos), msg))
+// A bailout panic is raised to indicate early termination
+// of a parsing function. It is recovered by the caller that is
+// able to continue parsing from the current position.
+type bailout struct{}
+
+// Records an error at the position pos.
+func (p *Parser) error(pos token.Pos, msg string) {
+	p.errors.Add(p.file.Position(pos), msg)
+}
+
+// Records an "expected ..." error at the position pos and
+// aborts parsing of the current construct.
+func (p *Parser) errorExpected(pos token.Pos, msg string) {
+	msg = "expected " + msg
+	if pos == p.pos {
+		// The error happened at the current position;
+		// make the error message more specific.
+		if p.tok == token.EOF {
+			msg += ", found EOF"
+		} else {
+			msg += ", found '" + p.lit + "'"
+		}
+	}
+	p.error(pos, msg)
+	panic(bailout{})
 }
 
 // Generates Ident from the current parser's state.
 func (p *Parser) genIdent() *ast.Ident {
 	p.test(token.IDENT)
 
-	return &ast.Ident{Name: p.lit, First: token.Pos(int(p.pos) - len(p.lit))}
+	return &ast.Ident{Name: p.lit, First: p.pos}
 }
 
 // Get next ident
@@ -633,13 +1288,31 @@ func (p *Parser) parseIdent() *ast.Ident {
 
 	p.test(token.IDENT)
 
-	return &ast.Ident{Name: p.lit, First: token.Pos(int(p.pos) - len(p.lit))}
+	return &ast.Ident{Name: p.lit, First: p.pos}
+}
+
+// Appends the current token's literal to s. prev is the
+// previous token in s. Adjacent words are separated with a space,
+// like in "varchar2(20 char)", and string literals are quoted.
+func (p *Parser) appendLit(s string, prev token.Token) string {
+	word := p.tok == token.IDENT || p.tok == token.NUMBER || token.IsKeyword(p.tok)
+	prevWord := prev == token.IDENT || prev == token.NUMBER || token.IsKeyword(prev)
+
+	if word && prevWord {
+		s += " "
+	}
+
+	if p.tok == token.STRING {
+		return s + "'" + p.lit + "'"
+	}
+
+	return s + p.lit
 }
 
 // Test current token
 func (p *Parser) test(tok token.Token) {
 	if p.tok != tok {
-		p.panic(fmt.Sprintf("Expected token %s, got %s", tok, p.tok))
+		p.errorExpected(p.pos, "'"+tok.String()+"'")
 	}
 }
 
@@ -677,14 +1350,21 @@ func (p *Parser) parseFieldList() *ast.FieldList {
 //	TODO: Has to be refactored into a more
 //	      smaller chunks
 func (p *Parser) parseParam() *ast.Field {
+	p.next()
+
+	return p.parseParamDecl()
+}
+
+// Parse parameter or record field, starting from the
+// current token
+func (p *Parser) parseParamDecl() *ast.Field {
 	// Don't use p.scanIdent here, because
 	// function/procedure/record parameter or record field
 	// can be a keyword as well as identifier, so we just
 	// scan what we can and treat scanned literal as Ident
-	p.next()
 	ident := &ast.Ident{
 		Name:  p.lit,
-		First: token.Pos(int(p.pos) - len(p.lit)),
+		First: p.pos,
 	}
 
 	var doc *ast.CommentGroup
@@ -708,6 +1388,8 @@ func (p *Parser) parseParam() *ast.Field {
 		}
 	}
 
+	noCopy := false
+
 	var parType *ast.Ident
 
 	// If parameter is IN or not specified, then at this point we
@@ -721,8 +1403,14 @@ func (p *Parser) parseParam() *ast.Field {
 		// parsed
 		parType = p.genIdent()
 	} else {
-		// Need to move forward to parse type
-		parType = p.parseIdent()
+		// Need to move forward to parse type. OUT and IN OUT
+		// parameters may have the NOCOPY hint before the type
+		p.next()
+		if p.tok == token.IDENT && p.lit == "nocopy" {
+			noCopy = true
+			p.next()
+		}
+		parType = p.genIdent()
 	}
 
 	var balance int
@@ -752,24 +1440,29 @@ func (p *Parser) parseParam() *ast.Field {
 		// stop when we met COMMA.
 		commaInsideParens := balance > 0 && p.tok == token.COMMA
 
-		if commaInsideParens || (p.tok != token.EOF && p.tok != token.DEFAULT && p.tok != token.COMMA && p.tok != token.RPAREN) {
+		// A semicolon never appears in a parameter declaration,
+		// so stop there to not consume the following declarations
+		// if the parameter list isn't closed.
+		if commaInsideParens || (p.tok != token.EOF && p.tok != token.DEFAULT && p.tok != token.ASSIGN && p.tok != token.COMMA && p.tok != token.RPAREN && p.tok != token.SEMICOLON) {
 			parType.Name = parType.Name + p.lit
 		} else {
 			break
 		}
 	}
 
+	parType.Last = p.prevEnd
+
 	// Scan default params
 	var start token.Pos
 	var name string
-	if p.tok == token.DEFAULT {
+	if p.tok == token.DEFAULT || p.tok == token.ASSIGN {
 		for {
 			p.next()
 
 			// Remember start position only if current token is
 			// the first in a default value
-			if int(start) == 0 {
-				start = token.Pos(int(p.pos) - len(p.lit))
+			if !start.IsValid() {
+				start = p.pos
 			}
 
 			var inParens string
@@ -785,7 +1478,10 @@ func (p *Parser) parseParam() *ast.Field {
 				p.next()
 			}
 
-			if p.tok != token.EOF && p.tok != token.COMMA && p.tok != token.RPAREN {
+			if p.tok == token.STRING {
+				// The scanner removes quotes from string literals
+				name = name + "'" + p.lit + "'"
+			} else if p.tok != token.EOF && p.tok != token.COMMA && p.tok != token.RPAREN && p.tok != token.SEMICOLON {
 				name = name + p.lit
 			} else {
 				break
@@ -795,17 +1491,18 @@ func (p *Parser) parseParam() *ast.Field {
 
 	var def *ast.Ident
 	if name != "" {
-		def = &ast.Ident{First: start, Name: name}
+		def = &ast.Ident{First: start, Last: p.prevEnd, Name: name}
 	}
 
 	return &ast.Field{
-		Doc:  doc,
-		Name: ident,
-		T:    parType,
-		Mod:  typ,
-		Def:  def,
-		Null: false,
-		Kind: ast.VPar,
+		Doc:    doc,
+		Name:   ident,
+		T:      parType,
+		Mod:    typ,
+		NoCopy: noCopy,
+		Def:    def,
+		Null:   false,
+		Kind:   ast.VPar,
 	}
 }
 
@@ -822,10 +1519,13 @@ func (p *Parser) scanBalancedParens() string {
 	// included into the result
 	lit := "("
 
+	prev := token.LPAREN
+
 	for balance != 0 && p.tok != token.EOF {
 		p.next()
 
-		lit = lit + p.lit
+		lit = p.appendLit(lit, prev)
+		prev = p.tok
 
 		if p.tok == token.LPAREN {
 			balance += 1
@@ -837,13 +1537,162 @@ func (p *Parser) scanBalancedParens() string {
 	return lit
 }
 
-// Returns function modificators: pipelined,
-// deterministic or result_cache is enabled
-// TODO: Parse function modificators(pipelined, deterministic,
+// Parses function's clauses that follow the return type:
+//
+//	deterministic
+//	pipelined [using [schema.]impl_type]
+//	result_cache [relies_on (object, ...)]
+//	parallel_enable [(partition arg by ...)]
+//	aggregate using [schema.]impl_type
+//	sql_macro [([type =>] {table | scalar})]
+//	accessible by ([unit_kind] [schema.]unit_name, ...)
+//
+// Clauses may follow in any order. Stops at the first token
+// that doesn't start a clause.
+func (p *Parser) parseFuncOpts(f *ast.FuncSpec) {
+	for {
+		switch {
+		case p.tok == token.DETERMINISTIC:
+			f.Deterministic = true
+			p.next()
+		case p.tok == token.PIPELINED:
+			f.Pipelined = true
+			p.next()
+
+			// Polymorphic table functions:
+			// pipelined {row | table} polymorphic using pkg
+			for p.tok == token.TABLE || p.isWord("row") || p.isWord("polymorphic") {
+				p.next()
+			}
+
+			if p.isWord("using") {
+				p.next()
+				f.PipelinedUsing = p.parseQualifiedName()
+			}
+		case p.tok == token.RESULT_CACHE:
+			f.ResultCache = true
+			p.next()
+
+			if p.isWord("relies_on") {
+				p.next()
+				f.ReliesOn = p.parseNameList()
+			}
+		case p.isWord("parallel_enable"):
+			f.ParallelEnable = true
+			p.next()
+
+			if p.tok == token.LPAREN {
+				start := p.pos
+				text := p.scanBalancedParens()
+
+				// Remove the enclosing parens
+				f.Partition = &ast.Ident{Name: text[1 : len(text)-1], First: start + 1, Last: p.pos}
+				p.next()
+			}
+		case p.isWord("aggregate"):
+			p.next()
+			if !p.isWord("using") {
+				p.errorExpected(p.pos, "'using'")
+			}
+			p.next()
+			f.AggregateUsing = p.parseQualifiedName()
+		case p.isWord("sql_macro"):
+			p.next()
+
+			// Table macro is the default one
+			kind := &ast.Ident{Name: "table", First: p.pos}
+			if p.tok == token.LPAREN {
+				// Skip the optional "type =>"
+				for p.tok != token.EOF && p.tok != token.RPAREN {
+					p.next()
+					if p.tok == token.TABLE || p.tok == token.IDENT {
+						kind = &ast.Ident{Name: p.lit, First: p.pos}
+					}
+				}
+				p.expect(token.RPAREN)
+			}
+			f.SQLMacro = kind
+		case p.isWord("accessible"):
+			p.next()
+			p.expect(token.BY)
+			f.AccessibleBy = p.parseAccessors()
+		default:
+			return
+		}
+	}
+}
+
+// Words that start function's clauses after the return type,
+// besides the deterministic, pipelined and result_cache keywords.
+// They aren't reserved, like methodWords.
+var funcClauseWords = map[string]bool{
+	"parallel_enable": true,
+	"aggregate":       true,
+	"sql_macro":       true,
+	"accessible":      true,
+}
+
+// Parses a parenthesized list of schema object names, like
+// the RELIES_ON list: (table1, hr.table2)
+func (p *Parser) parseNameList() []*ast.Ident {
+	var res []*ast.Ident
+
+	p.expect(token.LPAREN)
+	for p.tok != token.RPAREN {
+		res = append(res, p.parseQualifiedName())
+		if p.tok != token.RPAREN {
+			p.expect(token.COMMA)
+		}
+	}
+	p.next()
+
+	return res
+}
+
+// Parses the list of the ACCESSIBLE BY clause:
 //
-//	result_cache
-func (p *Parser) parseFuncOpts() (bool, bool, bool) {
-	return false, false, false
+//	([unit_kind] [schema.]unit_name, ...)
+func (p *Parser) parseAccessors() []*ast.Accessor {
+	var res []*ast.Accessor
+
+	p.expect(token.LPAREN)
+	for {
+		a := &ast.Accessor{}
+
+		switch p.tok {
+		case token.FUNCTION, token.PROCEDURE, token.PACKAGE, token.TYPE:
+			a.Kind = p.lit
+			p.next()
+		case token.IDENT:
+			// "trigger" isn't a keyword
+			if p.lit == "trigger" {
+				a.Kind = p.lit
+				p.next()
+			}
+		}
+
+		a.Schema, a.Name = p.parseObjectName()
+		res = append(res, a)
+
+		if p.tok == token.RPAREN {
+			break
+		}
+		p.expect(token.COMMA)
+	}
+	p.next()
+
+	return res
+}
+
+// Parses an object name qualified with the schema and returns
+// it as a single identifier, like "hr.employees"
+func (p *Parser) parseQualifiedName() *ast.Ident {
+	schema, name := p.parseObjectName()
+	if schema == nil {
+		return name
+	}
+
+	return &ast.Ident{Name: schema.Name + "." + name.Name, First: schema.First, Last: name.End()}
 }
 
 func (p *Parser) parseFuncResult() *ast.Ident {
@@ -851,21 +1700,37 @@ func (p *Parser) parseFuncResult() *ast.Ident {
 	p.test(token.RETURN)
 
 	var name string
+	var first token.Pos
+	prev := token.EOF
 
+	// Object type methods are delimited with commas and
+	// the closing paren, not with semicolons. Standalone
+	// functions have their body after IS or AS.
 	for {
 		p.next()
 		if p.tok != token.EOF &&
 			p.tok != token.RESULT_CACHE &&
 			p.tok != token.DETERMINISTIC &&
 			p.tok != token.PIPELINED &&
-			p.tok != token.SEMICOLON {
-			name = name + p.lit
+			!(p.tok == token.IDENT && funcClauseWords[p.lit]) &&
+			p.tok != token.SEMICOLON &&
+			p.tok != token.COMMA &&
+			p.tok != token.RPAREN &&
+			p.tok != token.AUTHID &&
+			p.tok != token.IS &&
+			(p.tok != token.AS || name == "self") {
+			// "return self as result"
+			if !first.IsValid() {
+				first = p.pos
+			}
+			name = p.appendLit(name, prev)
+			prev = p.tok
 		} else {
 			break
 		}
 	}
 
-	return &ast.Ident{Name: name, First: token.Pos(int(p.pos) - len(name))}
+	return &ast.Ident{Name: name, First: first, Last: p.prevEnd}
 }
 
 func (p *Parser) parseCursorResult() *ast.Ident {
@@ -873,20 +1738,36 @@ func (p *Parser) parseCursorResult() *ast.Ident {
 	p.test(token.RETURN)
 
 	var name string
+	var first token.Pos
 
 	for {
 		p.next()
 		if p.tok != token.EOF && p.tok != token.IS && p.tok != token.SEMICOLON {
+			if !first.IsValid() {
+				first = p.pos
+			}
 			name = name + p.lit
 		} else {
 			break
 		}
 	}
 
-	return &ast.Ident{Name: name, First: token.Pos(int(p.pos) - len(name))}
+	return &ast.Ident{Name: name, First: first, Last: p.prevEnd}
 }
 
 func (p *Parser) parseFuncSpec() *ast.FuncSpec {
+	fSpec := p.parseFuncHeader()
+
+	p.scanTo(token.SEMICOLON)
+
+	return fSpec
+}
+
+// Parses function's or procedure's name, parameters,
+// return type and options. We are at the FUNCTION or PROCEDURE
+// keyword. When the function returns, we are at the first token
+// that isn't a part of the header.
+func (p *Parser) parseFuncHeader() *ast.FuncSpec {
 	var ftype ast.FuncType
 
 	if p.tok == token.PROCEDURE {
@@ -895,32 +1776,27 @@ func (p *Parser) parseFuncSpec() *ast.FuncSpec {
 		ftype = ast.FtFunc
 	}
 
-	doc := p.leadComment
-	name := p.parseIdent()
-	var params *ast.FieldList
-	var typ *ast.Ident
-	var pipelined, deterministic, resultCache bool
+	fSpec := &ast.FuncSpec{
+		Doc:   p.leadComment,
+		First: p.pos,
+		Ftype: ftype,
+	}
 
+	// Standalone subprograms may be qualified with the schema
 	p.next()
+	_, fSpec.Name = p.parseObjectName()
+
 	if p.tok == token.LPAREN {
-		params = p.parseFieldList()
+		fSpec.Params = p.parseFieldList()
 	}
 
 	if ftype == ast.FtFunc {
-		typ = p.parseFuncResult()
-		pipelined, deterministic, resultCache = p.parseFuncOpts()
+		fSpec.T = p.parseFuncResult()
 	}
 
-	p.scanTo(token.SEMICOLON)
+	// Procedures may have the ACCESSIBLE BY clause too
+	p.parseFuncOpts(fSpec)
+	fSpec.Last = p.prevEnd
 
-	return &ast.FuncSpec{
-		Doc:           doc,
-		Name:          name,
-		Params:        params,
-		Ftype:         ftype,
-		T:             typ,
-		Pipelined:     pipelined,
-		Deterministic: deterministic,
-		ResultCache:   resultCache,
-	}
+	return fSpec
 }
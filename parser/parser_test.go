@@ -5,7 +5,11 @@
 package parser
 
 import (
+	"fmt"
 	"github.com/cyevgeniy/pldoc/ast"
+	"github.com/cyevgeniy/pldoc/scanner"
+	"github.com/cyevgeniy/pldoc/token"
+	"strings"
 	"testing"
 )
 
@@ -17,7 +21,7 @@ end test;
 `)
 
 func TestPackageCount(t *testing.T) {
-	file := ParseFile("testfile", src)
+	file, _ := ParseFile(token.NewFileSet(), "testfile", src)
 	if len(file.Packages) != 1 {
 		// Seems like parsePackage return empty package. Need to
 		// fix its return statement
@@ -25,8 +29,8 @@ func TestPackageCount(t *testing.T) {
 	}
 }
 
-var pckNames = []struct{
-	src []byte
+var pckNames = []struct {
+	src  []byte
 	name string
 }{
 	{
@@ -51,7 +55,7 @@ var pckNames = []struct{
 
 func TestPackageNames(t *testing.T) {
 	for i := 0; i < len(pckNames); i++ {
-		file := ParseFile("testfile", pckNames[i].src)
+		file, _ := ParseFile(token.NewFileSet(), "testfile", pckNames[i].src)
 		pckName := file.Packages[0].Name.Name
 		if pckName != string(pckNames[i].name) {
 			t.Fatalf("Package name error. Expected %s; Got: %s\n", string(pckNames[i].name), pckName)
@@ -84,7 +88,7 @@ var pckDocs = []struct {
 
 func TestPackageDoc(t *testing.T) {
 	for i := 0; i < len(pckDocs); i++ {
-		file := ParseFile("testfile", pckDocs[i].src)
+		file, _ := ParseFile(token.NewFileSet(), "testfile", pckDocs[i].src)
 		docText := file.Packages[0].Doc.Text()
 		if docText != string(pckDocs[i].doc) {
 			t.Fatalf("Package docs error. Expected %s; Got: %s\n", string(pckDocs[i].doc), docText)
@@ -116,7 +120,7 @@ var funcDocs = []struct {
 
 func TestFuncDocs(t *testing.T) {
 	for i := range funcDocs {
-		file := ParseFile("testfile", funcDocs[i].src)
+		file, _ := ParseFile(token.NewFileSet(), "testfile", funcDocs[i].src)
 		doc := file.Packages[0].FuncSpecs[0].Doc.Text()
 		if doc != funcDocs[i].doc {
 			t.Fatalf("Func docs error; Expected %s; Got %s\n", funcDocs[i].doc, doc)
@@ -153,7 +157,7 @@ var funcs = []struct {
 
 func TestFuncs(t *testing.T) {
 	for i := range funcs {
-		file := ParseFile("testfile", funcs[i].src)
+		file, _ := ParseFile(token.NewFileSet(), "testfile", funcs[i].src)
 		name := file.Packages[0].FuncSpecs[0].Name.Name
 		if name != funcs[i].name {
 			t.Fatalf("Func docs error; Expected %s; Got %s\n", funcs[i].name, name)
@@ -178,7 +182,7 @@ var balancedParensSrc = []struct {
 func TestBalancedParens(t *testing.T) {
 	var p Parser
 	for i := range balancedParensSrc {
-		p.Init("testfile", balancedParensSrc[i].Src, false)
+		p.Init(token.NewFileSet(), "testfile", balancedParensSrc[i].Src, false)
 
 		res := p.scanBalancedParens()
 
@@ -221,7 +225,7 @@ end tst;
 var parCnt []int = []int{1, 2, 2, 2, 6}
 
 func TestFuncParamsCnt(t *testing.T) {
-	file := ParseFile("testfile", []byte(paramsSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(paramsSrc))
 
 	fc := file.Packages[0].FuncSpecs
 	for i := range fc {
@@ -234,7 +238,7 @@ func TestFuncParamsCnt(t *testing.T) {
 var parNames []string = []string{"pvar", "pvar2", "pid_value", "pname_of_the_param", "pvar3", "pvar4", "pvar_row", "pvar5", "pvar_row2", "pvar6", "type", "exception", "pvar9"}
 
 func TestFuncParamNames(t *testing.T) {
-	file := ParseFile("testfile", []byte(paramsSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(paramsSrc))
 
 	fc := file.Packages[0].FuncSpecs
 	params := make([]string, 0, 9)
@@ -256,7 +260,7 @@ var parTypes []string = []string{"number", "varchar2", "pck_package.t_mytype", "
 	"mytable.id%type", "table_name%rowtype", "schema.tablename.column%type", "schema.tablename.\"column\"%type", "clob", "number"}
 
 func TestParamTypes(t *testing.T) {
-	file := ParseFile("testfile", []byte(paramsSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(paramsSrc))
 
 	fc := file.Packages[0].FuncSpecs
 	types := make([]string, 0)
@@ -277,7 +281,7 @@ func TestParamTypes(t *testing.T) {
 var parDefs []string = []string{"null", "3.14", "sysdate", "pck_const.id_default", "empty_clob()"}
 
 func TestParamDefaults(t *testing.T) {
-	file := ParseFile("testfile", []byte(paramsSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(paramsSrc))
 
 	fc := file.Packages[0].FuncSpecs
 	defs := make([]string, 0)
@@ -325,7 +329,7 @@ var varDocs []string = []string{"Exception's docs\n", "const docs\n", "var docs\
 
 func TestVarDocs(t *testing.T) {
 
-	file := ParseFile("testfile", []byte(varSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(varSrc))
 
 	vd := file.Packages[0].VarDecls
 
@@ -339,7 +343,7 @@ func TestVarDocs(t *testing.T) {
 var varNames []string = []string{"e_error", "c_const", "myvar", "myvar"}
 
 func TestVarNames(t *testing.T) {
-	file := ParseFile("testfile", []byte(varSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(varSrc))
 
 	vd := file.Packages[0].VarDecls
 
@@ -350,6 +354,65 @@ func TestVarNames(t *testing.T) {
 	}
 }
 
+var declSrc = `
+create or replace package test is
+c_name constant varchar2(20 char) not null := 'hello';
+v_cnt pls_integer default 0;
+v_sum number(20, 4);
+v_id t_ids.id%type not null := seq_id.nextval;
+
+procedure p(p_name varchar2 default 'none', p_sep char default ',', p_cnt number := 1);
+end test;
+`
+
+var declFields = []struct {
+	typ  string
+	null bool
+	def  string
+	str  string
+}{
+	{"varchar2(20 char)", true, "'hello'", "c_name constant varchar2(20 char) not null := 'hello'"},
+	{"pls_integer", false, "0", "v_cnt pls_integer := 0"},
+	{"number(20,4)", false, "", "v_sum number(20,4)"},
+	{"t_ids.id%type", true, "seq_id.nextval", "v_id t_ids.id%type not null := seq_id.nextval"},
+}
+
+func TestVarTypes(t *testing.T) {
+	file, err := ParseFile(token.NewFileSet(), "testfile", []byte(declSrc))
+	if err != nil {
+		t.Fatalf("Var types exception. Unexpected error: %s", err)
+	}
+
+	vd := file.Packages[0].VarDecls
+	if len(vd) != len(declFields) {
+		t.Fatalf("Var types exception. Expected %d variables; Got: %d", len(declFields), len(vd))
+	}
+
+	for i, exp := range declFields {
+		if vd[i].T.Name != exp.typ || vd[i].Null != exp.null || vd[i].Def.String() != exp.def {
+			t.Fatalf("Var types exception. Expected: %s, %v, %s; Got: %s, %v, %s",
+				exp.typ, exp.null, exp.def, vd[i].T.Name, vd[i].Null, vd[i].Def.String())
+		}
+
+		if vd[i].String() != exp.str {
+			t.Fatalf("Var types exception. Expected: %s; Got: %s", exp.str, vd[i].String())
+		}
+	}
+
+	// String defaults of parameters keep their quotes, and
+	// defaults can be set with the assignment operator too
+	params := file.Packages[0].FuncSpecs[0].Params.List
+	for i, def := range []string{"'none'", "','", "1"} {
+		if params[i].Def.String() != def {
+			t.Fatalf("Param defaults exception. Expected: %s; Got: %s", def, params[i].Def.String())
+		}
+	}
+
+	if params[2].T.Name != "number" {
+		t.Fatalf("Param defaults exception. Expected type: number; Got: %s", params[2].T.Name)
+	}
+}
+
 var curSrc = `
 create or replace package test is
 
@@ -372,7 +435,7 @@ end test;
 `
 
 func TestCurCount(t *testing.T) {
-	file := ParseFile("testfile", []byte(curSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(curSrc))
 
 	cnt := len(file.Packages[0].CursorDecls)
 
@@ -384,7 +447,7 @@ func TestCurCount(t *testing.T) {
 var curNames []string = []string{"cur1", "cur_2_cursor", "cur_3"}
 
 func TestCurNames(t *testing.T) {
-	file := ParseFile("testfile", []byte(curSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(curSrc))
 
 	curs := file.Packages[0].CursorDecls
 
@@ -398,7 +461,7 @@ func TestCurNames(t *testing.T) {
 var curParNames []string = []string{"par_1", "par2"}
 
 func TestCurParams(t *testing.T) {
-	file := ParseFile("testfile", []byte(curSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(curSrc))
 
 	params := file.Packages[0].CursorDecls[2].Params.List
 
@@ -410,7 +473,7 @@ func TestCurParams(t *testing.T) {
 }
 
 func TestListTypesCnt(t *testing.T) {
-	file := ParseFile("testfile", []byte(curSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(curSrc))
 
 	cnt := len(file.Packages[0].TypeDecls)
 	if cnt != 2 {
@@ -421,7 +484,7 @@ func TestListTypesCnt(t *testing.T) {
 var listNames []string = []string{"t_table", "t_varray"}
 
 func TestListTypesNames(t *testing.T) {
-	file := ParseFile("testfile", []byte(curSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(curSrc))
 
 	ltypes := file.Packages[0].TypeDecls
 
@@ -435,7 +498,7 @@ func TestListTypesNames(t *testing.T) {
 var listDocs []string = []string{"First documemtation string\n", "t_varray docs\n"}
 
 func TestListTypesDocs(t *testing.T) {
-	file := ParseFile("testfile", []byte(curSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(curSrc))
 
 	ltypes := file.Packages[0].TypeDecls
 
@@ -449,7 +512,7 @@ func TestListTypesDocs(t *testing.T) {
 var listTypes []string = []string{"number", "number"}
 
 func TestListTypesTypes(t *testing.T) {
-	file := ParseFile("testfile", []byte(curSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(curSrc))
 
 	ltypes := file.Packages[0].TypeDecls
 
@@ -460,6 +523,18 @@ func TestListTypesTypes(t *testing.T) {
 	}
 }
 
+func TestListTypesKinds(t *testing.T) {
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(curSrc))
+
+	ltypes := file.Packages[0].TypeDecls
+
+	for i, kind := range []ast.TypeKind{ast.TkTable, ast.TkVarray} {
+		if ltypes[i].Kind != kind {
+			t.Fatalf("List type's kinds exception; Expected: %v; Got: %v", kind, ltypes[i].Kind)
+		}
+	}
+}
+
 var recordsSrc = `
 create or replace package test is
 
@@ -484,7 +559,7 @@ end test;
 `
 
 func TestRecordTypesCount(t *testing.T) {
-	file := ParseFile("testfile", []byte(recordsSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(recordsSrc))
 
 	ltypes := file.Packages[0].TypeDecls
 
@@ -496,7 +571,7 @@ func TestRecordTypesCount(t *testing.T) {
 var recordNames []string = []string{"empinfo", "period"}
 
 func TestRecordtypesNames(t *testing.T) {
-	file := ParseFile("testfile", []byte(recordsSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(recordsSrc))
 
 	ltypes := file.Packages[0].TypeDecls
 
@@ -510,7 +585,7 @@ func TestRecordtypesNames(t *testing.T) {
 var recordDocs []string = []string{"EmpInfo documentation\n", "Period docs\n"}
 
 func TestRecordtypesDocs(t *testing.T) {
-	file := ParseFile("testfile", []byte(recordsSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(recordsSrc))
 
 	ltypes := file.Packages[0].TypeDecls
 
@@ -524,7 +599,7 @@ func TestRecordtypesDocs(t *testing.T) {
 var recordFieldsCnt []int = []int{3, 2}
 
 func TestRecordtypesFieldCount(t *testing.T) {
-	file := ParseFile("testfile", []byte(recordsSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(recordsSrc))
 
 	ltypes := file.Packages[0].TypeDecls
 
@@ -541,7 +616,7 @@ var recFieldNames [][]string = [][]string{
 }
 
 func TestRecordFieldNames(t *testing.T) {
-	file := ParseFile("testfile", []byte(recordsSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(recordsSrc))
 
 	ltypes := file.Packages[0].TypeDecls
 
@@ -560,7 +635,7 @@ var recFieldTypes [][]string = [][]string{
 }
 
 func TestRecordFieldTypes(t *testing.T) {
-	file := ParseFile("testfile", []byte(recordsSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(recordsSrc))
 
 	ltypes := file.Packages[0].TypeDecls
 
@@ -605,7 +680,7 @@ var refTest = []struct {
 }
 
 func TestRefCursors(t *testing.T) {
-	file := ParseFile("testfile", []byte(refCurSrc))
+	file, _ := ParseFile(token.NewFileSet(), "testfile", []byte(refCurSrc))
 
 	ltypes := file.Packages[0].TypeDecls
 
@@ -615,3 +690,790 @@ func TestRefCursors(t *testing.T) {
 		}
 	}
 }
+
+var errSrc = []struct {
+	src []byte
+	err string
+}{
+	{
+		[]byte("create package test is\ntype 123 is table of number;\nend test;"),
+		"testfile:2:6: expected 'IDENT', found '123'",
+	},
+	{
+		[]byte("create package test is\nc constant varchar2(10) := 'abc;\nend test;"),
+		"testfile:2:28: string literal not terminated",
+	},
+	{
+		[]byte("create package test is\nprocedure p;\nend other;"),
+		"testfile:3:5: incorrect package name, expected test",
+	},
+	{
+		[]byte("create package test"),
+		"testfile:1:20: expected is or as, found EOF",
+	},
+}
+
+func TestParseErrors(t *testing.T) {
+	for i := range errSrc {
+		_, err := ParseFile(token.NewFileSet(), "testfile", errSrc[i].src)
+
+		list, ok := err.(scanner.ErrorList)
+		if !ok {
+			t.Fatalf("Parse errors exception. Expected scanner.ErrorList; Got: %v; Testcase #%d", err, i)
+		}
+
+		found := false
+		for _, e := range list {
+			if e.Error() == errSrc[i].err {
+				found = true
+			}
+		}
+
+		if !found {
+			t.Fatalf("Parse errors exception. Expected: %s; Got: %s; Testcase #%d", errSrc[i].err, list, i)
+		}
+	}
+}
+
+func TestParseErrorsKeepPackages(t *testing.T) {
+	src := []byte(`
+create package broken is
+procedure p(a number;
+end broken;
+/
+create package test is
+procedure p;
+end test;
+/
+`)
+	file, err := ParseFile(token.NewFileSet(), "testfile", src)
+	if err == nil {
+		t.Fatalf("Expected a syntax error")
+	}
+
+	if len(file.Packages) != 2 || file.Packages[1].Name.Name != "test" {
+		t.Fatalf("Expected 2 packages to be parsed; Got %d packages", len(file.Packages))
+	}
+}
+
+var recoverySrc = `
+create or replace package test is
+
+-- f1 docs
+function f1 return number;
+
+type 123 is table of number;
+
+procedure p1(a number, b varchar2;
+
+c_const constant number := 1;
+
+type t_tab is table of number;
+
+cursor (a number) is select 1 from dual;
+procedure p2;
+
+end test;
+`
+
+var recoveryErrs = []string{
+	"testfile:7:1: declaration skipped up to line 9",
+	"testfile:7:6: expected 'IDENT', found '123'",
+	"testfile:9:1: declaration skipped up to line 11",
+	"testfile:9:34: expected ',', found ';'",
+	"testfile:15:1: declaration skipped up to line 16",
+	"testfile:15:8: expected 'IDENT', found '('",
+}
+
+var curNoQuerySrc = []struct {
+	src string
+	err string
+}{
+	{
+		"create package p is\ncursor c is;\nprocedure p1;\nend p;",
+		"testfile:2:12: expected select statement, found ';'",
+	},
+	{
+		"create package p is\ncursor c is",
+		"testfile:2:12: expected select statement, found EOF",
+	},
+}
+
+func TestCurNoQuery(t *testing.T) {
+	for i := range curNoQuerySrc {
+		_, err := ParseFile(token.NewFileSet(), "testfile", []byte(curNoQuerySrc[i].src))
+
+		list, ok := err.(scanner.ErrorList)
+		if !ok {
+			t.Fatalf("Cursor without query exception. Expected scanner.ErrorList; Got: %v; Testcase #%d", err, i)
+		}
+
+		found := false
+		for _, e := range list {
+			if e.Error() == curNoQuerySrc[i].err {
+				found = true
+			}
+		}
+
+		if !found {
+			t.Fatalf("Cursor without query exception. Expected: %s; Got: %s; Testcase #%d", curNoQuerySrc[i].err, list, i)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	file, err := ParseFile(token.NewFileSet(), "testfile", []byte(recoverySrc))
+
+	list, ok := err.(scanner.ErrorList)
+	if !ok {
+		t.Fatalf("Recovery exception. Expected scanner.ErrorList; Got: %v", err)
+	}
+
+	if len(list) != len(recoveryErrs) {
+		t.Fatalf("Recovery exception. Expected %d errors; Got: %s", len(recoveryErrs), list)
+	}
+
+	// ParseFile returns errors sorted by position
+	for i := range list {
+		if list[i].Error() != recoveryErrs[i] {
+			t.Fatalf("Recovery exception. Expected error: %s; Got: %s", recoveryErrs[i], list[i])
+		}
+	}
+
+	pck := file.Packages[0]
+
+	if len(pck.FuncSpecs) != 2 || pck.FuncSpecs[0].Name.Name != "f1" || pck.FuncSpecs[1].Name.Name != "p2" {
+		t.Fatalf("Recovery exception. Expected functions f1 and p2; Got %d functions", len(pck.FuncSpecs))
+	}
+
+	if pck.FuncSpecs[0].Doc.Text() != "f1 docs\n" {
+		t.Fatalf("Recovery exception. Unexpected docs: %s", pck.FuncSpecs[0].Doc.Text())
+	}
+
+	if len(pck.VarDecls) != 1 || pck.VarDecls[0].Name.Name != "c_const" {
+		t.Fatalf("Recovery exception. Expected constant c_const")
+	}
+
+	if len(pck.TypeDecls) != 1 || pck.TypeDecls[0].Name.Name != "t_tab" {
+		t.Fatalf("Recovery exception. Expected type t_tab")
+	}
+}
+
+var objTypeSrc = `
+-- Person type
+create or replace type person_t force as object (
+	-- Person's name
+	name varchar2(100),
+	birth_date date,
+
+	-- Creates a person
+	constructor function person_t(self in out nocopy person_t, name varchar2) return self as result,
+	-- Returns person's age
+	member function age return number deterministic,
+	static procedure register(p person_t),
+	map member function sort_key return varchar2,
+	not final not instantiable member procedure print
+) not final;
+/
+
+create or replace type body person_t as
+	member function age return number is
+	begin
+		return months_between(sysdate, birth_date) / 12;
+	end;
+end;
+/
+
+-- Employee type
+create type employee_t under hr.person_t (
+	salary number,
+	overriding member procedure print
+) final not instantiable
+/
+
+create type t_number_tab as table of number;
+/
+
+create or replace package test is
+procedure p;
+end test;
+/
+`
+
+func TestObjectTypes(t *testing.T) {
+	file, err := ParseFile(token.NewFileSet(), "testfile", []byte(objTypeSrc))
+	if err != nil {
+		t.Fatalf("Object types exception. Unexpected error: %s", err)
+	}
+
+	if len(file.Types) != 2 || len(file.Packages) != 1 {
+		t.Fatalf("Object types exception. Expected 2 types and 1 package; Got: %d and %d", len(file.Types), len(file.Packages))
+	}
+
+	person := file.Types[0]
+	if person.Name.Name != "person_t" || person.Doc.Text() != "Person type\n" || person.Final || !person.Instantiable {
+		t.Fatalf("Object types exception. Wrong person_t type: %s, %v, %v", person.Name, person.Final, person.Instantiable)
+	}
+
+	if len(person.Attrs) != 2 || person.Attrs[0].String() != "name varchar2(100)" || person.Attrs[0].Doc.Text() != "Person's name\n" {
+		t.Fatalf("Object types exception. Wrong attributes of person_t")
+	}
+
+	methods := []struct {
+		name         string
+		kind         ast.MethodKind
+		mapMethod    bool
+		final        bool
+		instantiable bool
+		result       string
+		doc          string
+	}{
+		{"person_t", ast.MkConstructor, false, false, true, "self as result", "Creates a person\n"},
+		{"age", ast.MkMember, false, false, true, "number", "Returns person's age\n"},
+		{"register", ast.MkStatic, false, false, true, "", ""},
+		{"sort_key", ast.MkMember, true, false, true, "varchar2", ""},
+		{"print", ast.MkMember, false, false, false, "", ""},
+	}
+
+	if len(person.Methods) != len(methods) {
+		t.Fatalf("Object types exception. Expected %d methods; Got %d", len(methods), len(person.Methods))
+	}
+
+	for i, m := range person.Methods {
+		exp := methods[i]
+		var result string
+		if m.Spec.T != nil {
+			result = m.Spec.T.Name
+		}
+
+		if m.Spec.Name.Name != exp.name || m.Kind != exp.kind || m.Map != exp.mapMethod ||
+			m.Final != exp.final || m.Instantiable != exp.instantiable ||
+			result != exp.result || m.Spec.Doc.Text() != exp.doc {
+			t.Fatalf("Object types exception. Wrong method #%d: %s", i, m.Spec.Name)
+		}
+	}
+
+	if person.Methods[0].Spec.Params.List[0].String() != "self in out nocopy person_t" {
+		t.Fatalf("Object types exception. Wrong constructor parameter: %s", person.Methods[0].Spec.Params.List[0])
+	}
+
+	emp := file.Types[1]
+	if emp.Name.Name != "employee_t" || emp.Super.Name != "hr.person_t" || !emp.Final || emp.Instantiable {
+		t.Fatalf("Object types exception. Wrong employee_t type")
+	}
+
+	if len(emp.Attrs) != 1 || len(emp.Methods) != 1 || !emp.Methods[0].Overriding {
+		t.Fatalf("Object types exception. Wrong employee_t elements")
+	}
+}
+
+func TestObjectTypeWords(t *testing.T) {
+	src := []byte(`
+create package test is
+member varchar2(10);
+final constant number := 1;
+procedure p(map number, order varchar2, static boolean);
+end test;
+/
+`)
+	file, err := ParseFile(token.NewFileSet(), "testfile", src)
+	if err != nil {
+		t.Fatalf("Object type words exception. Unexpected error: %s", err)
+	}
+
+	pck := file.Packages[0]
+
+	if len(pck.VarDecls) != 2 || pck.VarDecls[0].String() != "member varchar2(10)" || pck.VarDecls[1].Name.Name != "final" {
+		t.Fatalf("Object type words exception. Wrong variables: %v", pck.VarDecls)
+	}
+
+	params := pck.FuncSpecs[0].Params.List
+	for i, name := range []string{"map", "order", "static"} {
+		if params[i].Name.Name != name {
+			t.Fatalf("Object type words exception. Expected parameter: %s; Got: %s", name, params[i].Name.Name)
+		}
+	}
+}
+
+func TestPackageBodySkipped(t *testing.T) {
+	src := []byte(`
+create or replace package body test is
+	function f return number is
+	begin
+		if 1 = 1 then
+			return 1;
+		end if;
+	end;
+end test;
+/
+create or replace editionable package test is
+	function f return number;
+end test;
+/
+`)
+	file, err := ParseFile(token.NewFileSet(), "testfile", src)
+	if err != nil {
+		t.Fatalf("Package body exception. Unexpected error: %s", err)
+	}
+
+	if len(file.Packages) != 1 || len(file.Packages[0].FuncSpecs) != 1 {
+		t.Fatalf("Package body exception. Expected only the package specification")
+	}
+}
+
+var standaloneSrc = `
+-- Returns the order's total
+create or replace function sales.order_total(p_order_id in number) return number
+	authid definer
+is
+	l_total number;
+
+	function helper(p number) return number is
+	begin
+		return p;
+	end helper;
+begin
+	for r in (select * from order_lines) loop
+		l_total := l_total + case when r.qty > 0 then r.qty else 0 end;
+	end loop;
+
+	if l_total is null then
+		begin
+			l_total := 0;
+		exception
+			when others then null;
+		end;
+	end if;
+
+	case
+		when l_total > 100 then l_total := 100;
+		else null;
+	end case;
+
+	return helper(l_total);
+end order_total;
+/
+
+-- Logs a message
+create procedure log_msg(p_msg varchar2) as language java name 'Log.msg(java.lang.String)';
+/
+
+create or replace package test is
+procedure p;
+end test;
+/
+`
+
+func TestStandaloneFuncs(t *testing.T) {
+	file, err := ParseFile(token.NewFileSet(), "testfile", []byte(standaloneSrc))
+	if err != nil {
+		t.Fatalf("Standalone subprograms exception. Unexpected error: %s", err)
+	}
+
+	if len(file.Funcs) != 2 || len(file.Packages) != 1 {
+		t.Fatalf("Standalone subprograms exception. Expected 2 subprograms and 1 package; Got %d and %d", len(file.Funcs), len(file.Packages))
+	}
+
+	f := file.Funcs[0]
+	if f.Name.Name != "order_total" || f.Ftype != ast.FtFunc || f.T.Name != "number" ||
+		f.Doc.Text() != "Returns the order's total\n" || len(f.Params.List) != 1 {
+		t.Fatalf("Standalone subprograms exception. Wrong function order_total")
+	}
+
+	proc := file.Funcs[1]
+	if proc.Name.Name != "log_msg" || proc.Ftype != ast.FtProc || proc.Doc.Text() != "Logs a message\n" {
+		t.Fatalf("Standalone subprograms exception. Wrong procedure log_msg")
+	}
+}
+
+func TestSkipBody(t *testing.T) {
+	src := standaloneSrc[:strings.Index(standaloneSrc, "/\n")]
+
+	var p Parser
+	p.Init(token.NewFileSet(), "testfile", []byte(src), false)
+
+	p.scanTo(token.IS)
+	p.skipBody()
+
+	// We should stop at the semicolon after "end order_total"
+	if p.tok != token.SEMICOLON || p.file.Offset(p.pos) != strings.LastIndex(src, ";") {
+		t.Fatalf("Skip body exception. Stopped at %s, line %d", p.tok, p.file.Line(p.pos))
+	}
+}
+
+var subtypeSrc = `
+create or replace package test is
+
+-- Identifier
+subtype t_id is number(10) not null;
+
+subtype t_name is varchar2(100 char);
+
+-- Digit
+subtype t_digit is pls_integer range -9 .. 9 not null;
+
+subtype t_last_name is employees.last_name%type;
+
+procedure p(p_id t_id);
+
+end test;
+`
+
+var subtypes = []struct {
+	name    string
+	base    string
+	rng     string
+	notNull bool
+	doc     string
+}{
+	{"t_id", "number(10)", "", true, "Identifier\n"},
+	{"t_name", "varchar2(100 char)", "", false, ""},
+	{"t_digit", "pls_integer", "-9..9", true, "Digit\n"},
+	{"t_last_name", "employees.last_name%type", "", false, ""},
+}
+
+func TestSubtypes(t *testing.T) {
+	file, err := ParseFile(token.NewFileSet(), "testfile", []byte(subtypeSrc))
+	if err != nil {
+		t.Fatalf("Subtypes exception. Unexpected error: %s", err)
+	}
+
+	pck := file.Packages[0]
+	if len(pck.SubtypeDecls) != len(subtypes) || len(pck.VarDecls) != 0 || len(pck.FuncSpecs) != 1 {
+		t.Fatalf("Subtypes exception. Expected %d subtypes; Got %d", len(subtypes), len(pck.SubtypeDecls))
+	}
+
+	for i, st := range pck.SubtypeDecls {
+		var rng string
+		if st.Range != nil {
+			rng = st.Range.Name
+		}
+
+		if st.Name.Name != subtypes[i].name || st.Base.Name != subtypes[i].base ||
+			rng != subtypes[i].rng || st.NotNull != subtypes[i].notNull || st.Doc.Text() != subtypes[i].doc {
+			t.Fatalf("Subtypes exception. Expected: %v; Got: %s is %s range %s, %v", subtypes[i], st.Name, st.Base, rng, st.NotNull)
+		}
+	}
+}
+
+var funcOptsSrc = `
+create or replace package test is
+
+function f1(p number) return number deterministic result_cache relies_on (hr.employees, departments);
+
+function f2 return t_tab pipelined parallel_enable (partition p_cur by hash (id));
+
+function f3(p number) return number aggregate using hr.agg_impl;
+
+function f4 return varchar2 sql_macro(type => scalar);
+
+function f5 return varchar2 sql_macro accessible by (package hr.api, trigger trg, f6);
+
+function f6 return t_tab pipelined using impl_t;
+
+procedure p1 accessible by (function f1);
+
+end test;
+`
+
+func TestFuncOpts(t *testing.T) {
+	file, err := ParseFile(token.NewFileSet(), "testfile", []byte(funcOptsSrc))
+	if err != nil {
+		t.Fatalf("Function options exception. Unexpected error: %s", err)
+	}
+
+	fs := file.Packages[0].FuncSpecs
+	if len(fs) != 7 {
+		t.Fatalf("Function options exception. Expected 7 functions; Got %d", len(fs))
+	}
+
+	if !fs[0].Deterministic || !fs[0].ResultCache || len(fs[0].ReliesOn) != 2 ||
+		fs[0].ReliesOn[0].Name != "hr.employees" || fs[0].ReliesOn[1].Name != "departments" || fs[0].T.Name != "number" {
+		t.Fatalf("Function options exception. Wrong options of f1")
+	}
+
+	if !fs[1].Pipelined || !fs[1].ParallelEnable || fs[1].Partition.Name != "partition p_cur by hash(id)" || fs[1].T.Name != "t_tab" {
+		t.Fatalf("Function options exception. Wrong options of f2: %s", fs[1].Partition)
+	}
+
+	if fs[2].AggregateUsing.Name != "hr.agg_impl" {
+		t.Fatalf("Function options exception. Wrong options of f3")
+	}
+
+	if fs[3].SQLMacro.Name != "scalar" {
+		t.Fatalf("Function options exception. Wrong options of f4")
+	}
+
+	if fs[4].SQLMacro.Name != "table" || len(fs[4].AccessibleBy) != 3 ||
+		fs[4].AccessibleBy[0].String() != "package hr.api" ||
+		fs[4].AccessibleBy[1].String() != "trigger trg" ||
+		fs[4].AccessibleBy[2].String() != "f6" {
+		t.Fatalf("Function options exception. Wrong options of f5")
+	}
+
+	if !fs[5].Pipelined || fs[5].PipelinedUsing.Name != "impl_t" {
+		t.Fatalf("Function options exception. Wrong options of f6")
+	}
+
+	if len(fs[6].AccessibleBy) != 1 || fs[6].AccessibleBy[0].String() != "function f1" {
+		t.Fatalf("Function options exception. Wrong options of p1")
+	}
+}
+
+func TestFuncClauseWords(t *testing.T) {
+	src := []byte(`
+create package test accessible by (package hr.api) is
+aggregate number;
+accessible boolean;
+using varchar2(10);
+procedure p(parallel_enable number, sql_macro varchar2, relies_on date);
+function f return t_tab pipelined row polymorphic using impl_t;
+end test;
+/
+`)
+	file, err := ParseFile(token.NewFileSet(), "testfile", src)
+	if err != nil {
+		t.Fatalf("Function clause words exception. Unexpected error: %s", err)
+	}
+
+	pck := file.Packages[0]
+
+	if len(pck.AccessibleBy) != 1 {
+		t.Fatalf("Function clause words exception. Wrong accessible by clause of the package")
+	}
+
+	vars := []string{"aggregate number", "accessible boolean", "using varchar2(10)"}
+	if len(pck.VarDecls) != len(vars) {
+		t.Fatalf("Function clause words exception. Expected %d variables; Got: %d", len(vars), len(pck.VarDecls))
+	}
+	for i := range vars {
+		if pck.VarDecls[i].String() != vars[i] {
+			t.Fatalf("Function clause words exception. Expected variable: %s; Got: %s", vars[i], pck.VarDecls[i])
+		}
+	}
+
+	params := pck.FuncSpecs[0].Params.List
+	for i, name := range []string{"parallel_enable", "sql_macro", "relies_on"} {
+		if params[i].Name.Name != name {
+			t.Fatalf("Function clause words exception. Expected parameter: %s; Got: %s", name, params[i].Name.Name)
+		}
+	}
+
+	if f := pck.FuncSpecs[1]; !f.Pipelined || f.PipelinedUsing.String() != "impl_t" {
+		t.Fatalf("Function clause words exception. Wrong options of f")
+	}
+}
+
+var pragmaSrc = `
+create or replace package api is
+  pragma serially_reusable;
+
+  e_not_found exception;
+  pragma exception_init(e_not_found, -20001);
+
+  function old_f return number;
+  pragma deprecate(old_f, 'Use new_f instead');
+  pragma restrict_references(old_f, wnds, rnds);
+
+  function new_f return number;
+  pragma deprecate(api);
+end api;
+
+create or replace procedure log_msg(msg varchar2) is
+  pragma autonomous_transaction;
+  pragma inline(other_p, 'YES');
+  procedure nested is
+    pragma autonomous_transaction;
+  begin
+    null;
+  end;
+begin
+  null;
+end;
+
+create type t_obj as object (
+  id number,
+  member function get_id return number,
+  pragma restrict_references(get_id, wnds)
+);
+`
+
+func TestPragmas(t *testing.T) {
+	file, err := ParseFile(token.NewFileSet(), "testfile", []byte(pragmaSrc))
+	if err != nil {
+		t.Fatalf("Pragmas exception. Unexpected error: %s", err)
+	}
+
+	pck := file.Packages[0]
+	if len(pck.Pragmas) != 5 {
+		t.Fatalf("Pragmas exception. Expected 5 pragmas; Got %d", len(pck.Pragmas))
+	}
+
+	pckPragmas := pck.PackagePragmas()
+	if len(pckPragmas) != 2 || pckPragmas[0].Name.Name != "serially_reusable" ||
+		pckPragmas[1].Name.Name != "deprecate" {
+		t.Fatalf("Pragmas exception. Wrong package pragmas: %v", pckPragmas)
+	}
+
+	if pck.VarDecls[0].Code == nil || pck.VarDecls[0].Code.Name != "-20001" {
+		t.Fatalf("Pragmas exception. Expected error code -20001; Got %v", pck.VarDecls[0].Code)
+	}
+
+	oldF := pck.FuncSpecs[0]
+	if len(oldF.Pragmas) != 2 {
+		t.Fatalf("Pragmas exception. Expected 2 pragmas of old_f; Got %d", len(oldF.Pragmas))
+	}
+
+	dep := ast.FindPragma(oldF.Pragmas, "deprecate")
+	if dep == nil || len(dep.Args) != 2 || dep.Args[1].Name != "'Use new_f instead'" {
+		t.Fatalf("Pragmas exception. Wrong deprecate pragma of old_f")
+	}
+
+	rr := oldF.Pragmas[1]
+	if rr.Name.Name != "restrict_references" || len(rr.Args) != 3 || rr.Args[2].Name != "rnds" {
+		t.Fatalf("Pragmas exception. Wrong restrict_references pragma of old_f")
+	}
+
+	if len(pck.FuncSpecs[1].Pragmas) != 0 {
+		t.Fatalf("Pragmas exception. Expected no pragmas of new_f")
+	}
+
+	logMsg := file.Funcs[0]
+	if len(logMsg.Pragmas) != 1 || logMsg.Pragmas[0].Name.Name != "autonomous_transaction" {
+		t.Fatalf("Pragmas exception. Wrong pragmas of log_msg: %v", logMsg.Pragmas)
+	}
+
+	m := file.Types[0].Methods[0]
+	if len(file.Types[0].Attrs) != 1 || len(m.Spec.Pragmas) != 1 || m.Spec.Pragmas[0].Target() != "get_id" {
+		t.Fatalf("Pragmas exception. Wrong pragmas of t_obj.get_id")
+	}
+}
+
+var pckMetaSrc = `
+create or replace editionable package hr.api sharing = metadata
+  default collation using_nls_comp
+  authid current_user
+  accessible by (package hr.client, trigger trg)
+is
+  procedure p;
+end api;
+
+create package if not exists plain as
+end plain;
+`
+
+func TestPackageMetadata(t *testing.T) {
+	file, err := ParseFile(token.NewFileSet(), "testfile", []byte(pckMetaSrc))
+	if err != nil {
+		t.Fatalf("Package metadata exception. Unexpected error: %s", err)
+	}
+
+	if len(file.Packages) != 2 {
+		t.Fatalf("Package metadata exception. Expected 2 packages; Got %d", len(file.Packages))
+	}
+
+	pck := file.Packages[0]
+	if pck.Name.Name != "api" || pck.Schema == nil || pck.Schema.Name != "hr" {
+		t.Fatalf("Package metadata exception. Wrong package name or schema")
+	}
+
+	if pck.Edition == nil || pck.Edition.Name != "editionable" {
+		t.Fatalf("Package metadata exception. Expected editionable package")
+	}
+
+	if pck.Sharing == nil || pck.Sharing.Name != "metadata" {
+		t.Fatalf("Package metadata exception. Expected sharing = metadata")
+	}
+
+	if pck.AuthID == nil || pck.AuthID.Name != "current_user" {
+		t.Fatalf("Package metadata exception. Expected authid current_user")
+	}
+
+	if len(pck.AccessibleBy) != 2 || pck.AccessibleBy[0].String() != "package hr.client" ||
+		pck.AccessibleBy[1].String() != "trigger trg" {
+		t.Fatalf("Package metadata exception. Wrong accessible by clause")
+	}
+
+	if len(pck.FuncSpecs) != 1 {
+		t.Fatalf("Package metadata exception. Expected 1 procedure; Got %d", len(pck.FuncSpecs))
+	}
+
+	plain := file.Packages[1]
+	if plain.Name.Name != "plain" || plain.Schema != nil || plain.Edition != nil ||
+		plain.AuthID != nil || plain.Sharing != nil || plain.AccessibleBy != nil {
+		t.Fatalf("Package metadata exception. Expected package without metadata")
+	}
+}
+
+var positionsSrc = `create or replace package test is
+  -- Error
+  e_error exception;
+  c_max constant number := 10;
+  subtype t_id is number(10) not null;
+  type t_tab is table of varchar2(100);
+  cursor c_emp(p_id t_id) is select * from emp;
+  function get(p_id   number default 1) return   varchar2  deterministic;
+  pragma restrict_references(get, wnds);
+end test;
+`
+
+// Returns "line:column" of the position
+func lineCol(fset *token.FileSet, p token.Pos) string {
+	pos := fset.Position(p)
+	return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+}
+
+func TestPositions(t *testing.T) {
+	fset := token.NewFileSet()
+
+	// Positions of the second file shouldn't start from zero
+	if _, err := ParseFile(fset, "first", []byte("create package a is end a;")); err != nil {
+		t.Fatalf("Positions exception. Unexpected error: %s", err)
+	}
+
+	file, err := ParseFile(fset, "testfile", []byte(positionsSrc))
+	if err != nil {
+		t.Fatalf("Positions exception. Unexpected error: %s", err)
+	}
+
+	pck := file.Packages[0]
+	fs := pck.FuncSpecs[0]
+
+	cases := []struct {
+		name  string
+		node  ast.Node
+		start string
+		end   string
+	}{
+		{"package", pck, "1:19", "10:9"},
+		{"package name", pck.Name, "1:27", "1:31"},
+		{"exception", pck.VarDecls[0], "3:3", "3:20"},
+		{"constant", pck.VarDecls[1], "4:3", "4:30"},
+		{"subtype", pck.SubtypeDecls[0], "5:3", "5:38"},
+		{"subtype base", pck.SubtypeDecls[0].Base, "5:19", "5:29"},
+		{"type", pck.TypeDecls[0], "6:3", "6:39"},
+		{"cursor", pck.CursorDecls[0], "7:3", "7:47"},
+		{"function", fs, "8:3", "8:73"},
+		{"function name", fs.Name, "8:12", "8:15"},
+		{"params", fs.Params, "8:15", "8:40"},
+		{"param", fs.Params.List[0], "8:16", "8:39"},
+		{"param type", fs.Params.List[0].T, "8:23", "8:29"},
+		{"param default", fs.Params.List[0].Def, "8:38", "8:39"},
+		{"return type", fs.T, "8:50", "8:58"},
+		{"pragma", pck.Pragmas[0], "9:3", "9:40"},
+	}
+
+	for _, c := range cases {
+		if start := lineCol(fset, c.node.Start()); start != c.start {
+			t.Errorf("Positions exception. Wrong start of %s: expected %s; Got %s", c.name, c.start, start)
+		}
+
+		if end := lineCol(fset, c.node.End()); end != c.end {
+			t.Errorf("Positions exception. Wrong end of %s: expected %s; Got %s", c.name, c.end, end)
+		}
+	}
+
+	if pos := fset.Position(fs.Start()); pos.Filename != "testfile" {
+		t.Fatalf("Positions exception. Expected file testfile; Got %s", pos.Filename)
+	}
+}
@@ -5,76 +5,253 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"github.com/cyevgeniy/pldoc/ast"
+	"github.com/cyevgeniy/pldoc/jsondoc"
 	"github.com/cyevgeniy/pldoc/parser"
+	"github.com/cyevgeniy/pldoc/scanner"
 	"github.com/cyevgeniy/pldoc/template"
+	"github.com/cyevgeniy/pldoc/token"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 )
 
-func genFileSet(description string, files []string) (*ast.Files, error) {
-	var fileSet ast.Files = ast.Files{
-		Description: description,
-	}
+// The result of reading and parsing a file
+type parseResult struct {
+	file *ast.File // nil if the file couldn't be read
+	errs scanner.ErrorList
+}
 
-	for i := range files {
+// Reads and parses a file. Syntax errors are returned
+// with everything that could be parsed. If the file can't
+// be read, the error is returned in the list too.
+func parseOne(fset *token.FileSet, name string) parseResult {
+	var res parseResult
 
-		data, err := os.ReadFile(files[i])
-		if err != nil {
-			log.Fatal(files[i])
-			return nil, err
+	data, err := os.ReadFile(name)
+	if err == nil {
+		res.file, err = parser.ParseFile(fset, name, data)
+	}
+
+	if list, ok := err.(scanner.ErrorList); ok {
+		res.errs = list
+	} else if err != nil {
+		// The file's name is in the position
+		var pe *fs.PathError
+		if errors.As(err, &pe) {
+			err = pe.Err
 		}
 
-		file := parser.ParseFile(files[i], data)
-		fileSet.Add(file)
+		res.file = nil
+		res.errs.Add(token.Position{Filename: name}, err.Error())
+	}
+
+	return res
+}
+
+// Reads and parses the files, up to jobs files at a time. Results
+// are in the order of the names, whatever order the files are
+// parsed in, so the documentation is the same on every run.
+func parseFiles(fset *token.FileSet, names []string, jobs int) []parseResult {
+	res := make([]parseResult, len(names))
+
+	if jobs > len(names) {
+		jobs = len(names)
 	}
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
 
-	return &fileSet, nil
+	for j := 0; j < jobs; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				res[i] = parseOne(fset, names[i])
+			}
+		}()
+	}
 
+	for i := range names {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return res
 }
 
-func main() {
+// Reads and parses files, up to jobs files at a time. Files
+// with syntax errors are still added to the set with everything
+// that could be parsed from them, files that can't be read are
+// skipped. Errors of all files are returned as a scanner.ErrorList.
+func genFileSet(description string, files []string, jobs int) (*ast.Files, error) {
+	var fileSet ast.Files = ast.Files{
+		Description: description,
+		FileSet:     token.NewFileSet(),
+	}
 
-	var ext = flag.String("ext", "pks", "The extension of specification files")
-	var outDir = flag.String("output", ".", "The output directory for documentation")
+	var errs scanner.ErrorList
 
-	flag.Parse()
+	for _, r := range parseFiles(fileSet.FileSet, files, jobs) {
+		errs = append(errs, r.errs...)
+		if r.file != nil {
+			fileSet.Add(r.file)
+		}
+	}
 
-	args := flag.Args()
+	return &fileSet, errs.Err()
+}
 
-	packages := make([]string, 0)
+// Returns files with the extension in the directories, which
+// are walked recursively, and their paths relative to the directories
+func findFiles(dirs []string, ext string) ([]string, map[string]string, error) {
+	files := make([]string, 0)
+	paths := make(map[string]string)
 
-	for i := range args {
-		err := filepath.Walk(args[i], func(path string, info fs.FileInfo, err error) error {
+	for i := range dirs {
+		err := filepath.Walk(dirs[i], func(path string, info fs.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
 
-			if !info.IsDir() && strings.HasSuffix(info.Name(), "."+*ext) {
-				packages = append(packages, path)
+			if !info.IsDir() && strings.HasSuffix(info.Name(), "."+ext) {
+				files = append(files, path)
+
+				rel, err := filepath.Rel(dirs[i], path)
+				if err != nil || rel == "." {
+					// The root is the file itself
+					rel = info.Name()
+				}
+				paths[path] = rel
 			}
 
 			return nil
 		})
 
 		if err != nil {
-			panic(err)
+			return nil, nil, err
 		}
 	}
 
-	fset, err := genFileSet("Documentation", packages)
+	return files, paths, nil
+}
 
+// Prints warnings about unresolved references, if there are any
+func printWarnings(warnings scanner.ErrorList) {
+	if len(warnings) > 0 {
+		warnings.Sort()
+		log.Printf("%d warning(s):", len(warnings))
+		scanner.PrintError(os.Stderr, warnings)
+	}
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "doc":
+			docMain(os.Args[2:])
+			return
+		case "serve":
+			serveMain(os.Args[2:])
+			return
+		}
+	}
+
+	var ext = flag.String("ext", "pks", "The extension of specification files")
+	var outDir = flag.String("output", ".", "The output directory for documentation")
+	var sourceURL = flag.String("source-url", "", "URL template of the repository browser, like\n"+
+		"https://git.example.com/repo/blob/{rev}/{path}#L{line}.\n"+
+		"{path} is relative to the source directory")
+	var rev = flag.String("rev", "HEAD", "The revision that replaces {rev} in the source URL")
+	var format = flag.String("format", "html", "The output format: html, markdown or json")
+	var tags = flag.Bool("tags", false, "Show @param, @return and other tags of doc comments in their own sections")
+	var watch = flag.Bool("watch", false, "Keep running and regenerate the documentation when the sources change")
+	var jobs = flag.Int("j", runtime.NumCPU(), "The number of files parsed in parallel")
+
+	flag.Parse()
+
+	execute := template.Execute
+	switch *format {
+	case "html":
+	case "markdown":
+		execute = template.ExecuteMarkdown
+	case "json":
+		execute = func(dir string, f *ast.Files, opts template.Options) error {
+			return jsondoc.Execute(dir, f, opts.Paths)
+		}
+	default:
+		log.Fatalf("unknown output format %q", *format)
+	}
+
+	if *watch {
+		src := newSources(flag.Args(), *ext, *jobs)
+		if _, err := src.update(); err != nil {
+			log.Fatal(err)
+		}
+
+		w := &watcher{
+			src: src,
+			dir: *outDir,
+			opts: template.Options{
+				SourceURL: *sourceURL,
+				Rev:       *rev,
+				Tags:      *tags,
+			},
+		}
+
+		// Only HTML pages are regenerated selectively
+		if *format != "html" {
+			w.execute = execute
+		}
+
+		w.generate(nil)
+		log.Printf("watching %s for changes", strings.Join(flag.Args(), ", "))
+		src.watch(time.Second, w.generate, func(err error) {
+			log.Print(err)
+		})
+		return
+	}
+
+	packages, paths, err := findFiles(flag.Args(), *ext)
 	if err != nil {
 		panic(err)
 	}
 
-	err = template.Execute(*outDir, fset)
+	fset, err := genFileSet("Documentation", packages, *jobs)
+
+	if err != nil {
+		if _, ok := err.(scanner.ErrorList); !ok {
+			log.Fatal(err)
+		}
+
+		// Report syntax errors, but still generate documentation
+		// for everything that has been parsed.
+		scanner.PrintError(os.Stderr, err)
+	}
+
+	var warnings scanner.ErrorList
+	err = execute(*outDir, fset, template.Options{
+		SourceURL: *sourceURL,
+		Rev:       *rev,
+		Paths:     paths,
+		Tags:      *tags,
+		Warnings:  &warnings,
+	})
 
 	if err != nil {
 		panic(err)
 	}
+
+	printWarnings(warnings)
 }
@@ -0,0 +1,295 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/cyevgeniy/pldoc/scanner"
+	"github.com/cyevgeniy/pldoc/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Writes n package specifications with funcs subprograms each
+// into the directory. Every tenth package has a syntax error
+// if broken is set. Returns the names of the files.
+func writeCorpus(t testing.TB, dir string, n, funcs int, broken bool) []string {
+	var names []string
+
+	for i := 0; i < n; i++ {
+		var b strings.Builder
+		fmt.Fprintf(&b, "-- Package number %d\ncreate or replace package pck_%d is\n\n", i, i)
+
+		for j := 0; j < funcs; j++ {
+			fmt.Fprintf(&b, "-- Returns the value of the item %d.\n", j)
+			fmt.Fprintf(&b, "-- @param p_id The identifier of the item\n")
+			fmt.Fprintf(&b, "function get_%d(p_id in number, p_name varchar2 default 'x') return varchar2;\n\n", j)
+			fmt.Fprintf(&b, "type t_rec_%d is record (\n  -- The identifier\n  id number,\n  name varchar2(100)\n);\n\n", j)
+		}
+
+		if broken && i%10 == 0 {
+			b.WriteString("procedure broken(a number;\n\n")
+		}
+
+		fmt.Fprintf(&b, "end pck_%d;\n/\n", i)
+
+		name := filepath.Join(dir, fmt.Sprintf("pck_%04d.pks", i))
+		if err := os.WriteFile(name, []byte(b.String()), 0666); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func TestGenFileSetOrder(t *testing.T) {
+	names := writeCorpus(t, t.TempDir(), 50, 3, true)
+
+	for _, jobs := range []int{1, 4, 100} {
+		f, err := genFileSet("Documentation", names, jobs)
+
+		list, ok := err.(scanner.ErrorList)
+		if !ok {
+			t.Fatalf("jobs=%d: expected scanner.ErrorList; got %v", jobs, err)
+		}
+
+		// Every broken file has its errors reported
+		files := make(map[string]bool)
+		for _, e := range list {
+			files[e.Pos.Filename] = true
+		}
+		if len(files) != 5 {
+			t.Errorf("jobs=%d: expected errors in 5 files; got %d", jobs, len(files))
+		}
+
+		if len(f.Files) != len(names) {
+			t.Fatalf("jobs=%d: expected %d files; got %d", jobs, len(names), len(f.Files))
+		}
+
+		for i := range names {
+			if f.Files[i].Name != names[i] {
+				t.Fatalf("jobs=%d: file #%d is %s; expected %s", jobs, i, f.Files[i].Name, names[i])
+			}
+
+			pcks := f.Files[i].Packages
+			if len(pcks) != 1 || pcks[0].Name.Name != fmt.Sprintf("pck_%d", i) {
+				t.Fatalf("jobs=%d: unexpected packages in %s", jobs, names[i])
+			}
+		}
+	}
+}
+
+func TestGenFileSetReadError(t *testing.T) {
+	dir := t.TempDir()
+	names := writeCorpus(t, dir, 3, 1, true)
+
+	// A directory can't be read as a file either
+	if err := os.Mkdir(filepath.Join(dir, "dir.pks"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	unreadable := []string{
+		filepath.Join(dir, "missing.pks"),
+		filepath.Join(dir, "dir.pks"),
+		filepath.Join(dir, "gone.pks"),
+	}
+	names = append(names[:1], append(unreadable, names[1:]...)...)
+
+	f, err := genFileSet("Documentation", names, 2)
+
+	list, ok := err.(scanner.ErrorList)
+	if !ok {
+		t.Fatalf("expected scanner.ErrorList; got %v", err)
+	}
+
+	// Read errors are reported in the order of the files,
+	// along with the syntax error of the first file
+	var got []string
+	for _, e := range list {
+		if e.Pos.Line == 0 {
+			got = append(got, e.Pos.Filename)
+		}
+	}
+	if strings.Join(got, " ") != strings.Join(unreadable, " ") {
+		t.Errorf("read errors: got %v, want %v", got, unreadable)
+	}
+
+	if len(list) == len(got) {
+		t.Error("the syntax error is missing")
+	}
+
+	if len(f.Files) != 3 {
+		t.Errorf("expected the readable files to be parsed; got %d files", len(f.Files))
+	}
+}
+
+func BenchmarkGenFileSet(b *testing.B) {
+	names := writeCorpus(b, b.TempDir(), 500, 40, false)
+
+	for _, jobs := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("j=%d", jobs), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := genFileSet("Documentation", names, jobs); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestFindFilesPaths(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "hr", "api"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{
+		filepath.Join(root, "a.pks"),
+		filepath.Join(root, "hr", "api", "emp.pks"),
+		filepath.Join(root, "hr", "readme.txt"),
+	}
+	for _, name := range names {
+		if err := os.WriteFile(name, nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, paths, err := findFiles([]string{root}, "pks")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files; got %v", files)
+	}
+
+	want := map[string]string{
+		names[0]: "a.pks",
+		names[1]: filepath.Join("hr", "api", "emp.pks"),
+	}
+	for name, rel := range want {
+		if paths[name] != rel {
+			t.Errorf("path of %s: got %q, want %q", name, paths[name], rel)
+		}
+	}
+
+	// The root is the file itself
+	_, paths, err = findFiles([]string{names[1]}, "pks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paths[names[1]] != "emp.pks" {
+		t.Errorf("path of %s: got %q, want %q", names[1], paths[names[1]], "emp.pks")
+	}
+}
+
+// Returns the documentation of a generated package
+func testSite(t *testing.T) *template.Site {
+	names := writeCorpus(t, t.TempDir(), 1, 1, false)
+
+	f, err := genFileSet("Documentation", names, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	site, err := template.NewSite(f, template.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return site
+}
+
+func TestServePages(t *testing.T) {
+	srv := newServer(testSite(t))
+
+	tests := []struct {
+		path   string
+		status int
+		ctype  string
+		reload bool
+	}{
+		{"/", http.StatusOK, "text/html", true},
+		{"/pck_0.html", http.StatusOK, "text/html", true},
+		{"/main.css", http.StatusOK, "text/css", false},
+		{"/search-index.js", http.StatusOK, "javascript", false},
+		{"/no_such_page.html", http.StatusNotFound, "", false},
+		{"/../pck_0.pks", http.StatusNotFound, "", false},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.path, w.Code, tt.status)
+			continue
+		}
+
+		if tt.status != http.StatusOK {
+			continue
+		}
+
+		if ctype := w.Header().Get("Content-Type"); !strings.Contains(ctype, tt.ctype) {
+			t.Errorf("%s: Content-Type %q, want %s", tt.path, ctype, tt.ctype)
+		}
+
+		body := w.Body.String()
+		if got := strings.Contains(body, reloadScript+"\n</body>"); got != tt.reload {
+			t.Errorf("%s: reload script injected: %v, want %v", tt.path, got, tt.reload)
+		}
+	}
+}
+
+func TestServeReload(t *testing.T) {
+	srv := newServer(testSite(t))
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + reloadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ctype := resp.Header.Get("Content-Type"); ctype != "text/event-stream" {
+		t.Fatalf("Content-Type %q, want text/event-stream", ctype)
+	}
+
+	events := make(chan string)
+	go func() {
+		r := bufio.NewReader(resp.Body)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(events)
+				return
+			}
+			if line != "\n" {
+				events <- line
+			}
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		srv.reload(testSite(t))
+
+		select {
+		case e := <-events:
+			if e != "data: reload\n" {
+				t.Fatalf("event %q, want data: reload", e)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no event after reload #%d", i+1)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package scanner
 
 import (
 	"github.com/cyevgeniy/pldoc/token"
-	"log"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -10,27 +9,50 @@ import (
 
 const eof = -1
 
+// An ErrorHandler may be provided to Scanner.Init. If a syntax error is
+// encountered and a handler was installed, the handler is called with a
+// position and an error message. The position points to the beginning of
+// the offending token.
+type ErrorHandler func(pos token.Position, msg string)
+
 type Scanner struct {
 	file *token.File
 	src  []byte
+	err  ErrorHandler
 
 	ch         rune
 	offset     int
 	rdOffset   int
 	lineOffset int
+
+	// public state - ok to modify
+	ErrorCount int // number of errors encountered
 }
 
-func (s *Scanner) Init(file *token.File, src []byte) {
+func (s *Scanner) Init(file *token.File, src []byte, err ErrorHandler) {
 	s.file = file
 	s.src = src
+	s.err = err
 	s.ch = ' '
 	s.offset = 0
 	s.rdOffset = 0
 	s.lineOffset = 0
+	s.ErrorCount = 0
 }
 
-func (s *Scanner) panic(msg string) {
-	log.Fatalf("%s, file: %s", msg, s.file.Filename)
+// Reports an error at the offset offs and increments
+// the error count.
+func (s *Scanner) error(offs int, msg string) {
+	if s.err != nil {
+		s.err(s.file.Position(s.file.Pos(offs)), msg)
+	}
+	s.ErrorCount++
+}
+
+// Returns the offset of the character that
+// follows the last scanned token
+func (s *Scanner) Offset() int {
+	return s.offset
 }
 
 func (s *Scanner) next() {
@@ -42,7 +64,7 @@ func (s *Scanner) next() {
 		r, w := utf8.DecodeRune(s.src[s.rdOffset:])
 
 		if r == utf8.RuneError && w == 1 {
-			s.panic("Illegal UTF-8 encoding")
+			s.error(s.offset, "illegal UTF-8 encoding")
 		}
 
 		s.rdOffset += w
@@ -113,7 +135,7 @@ func (s *Scanner) scanComment() string {
 		}
 	}
 
-	s.panic("Comment not terminated")
+	s.error(offs, "comment not terminated")
 
 exit:
 	lit := s.src[offs:s.offset]
@@ -156,7 +178,9 @@ func isDigit(ch rune) bool {
 func (s *Scanner) scanIdentifier() string {
 	offs := s.offset
 
-	for isDigit(s.ch) || isLetter(s.ch) {
+	// Besides letters and digits, PL/SQL identifiers may
+	// contain dollar and number signs, like v$session
+	for isDigit(s.ch) || isLetter(s.ch) || s.ch == '$' || s.ch == '#' {
 		s.next()
 	}
 
@@ -168,6 +192,10 @@ func (s *Scanner) scanString() string {
 	offs := s.offset
 	// 'Hello, World'
 	for s.ch != '\'' {
+		if s.ch < 0 {
+			s.error(offs-1, "string literal not terminated")
+			return string(s.src[offs:s.offset])
+		}
 		s.next()
 	}
 
@@ -202,6 +230,7 @@ func (s *Scanner) Scan() (pos token.Pos, tok token.Token, lit string) {
 	s.skipWhitespace()
 
 	pos = s.file.Pos(s.offset)
+	offs := s.offset
 
 	switch ch := s.ch; {
 	case isLetter(ch):
@@ -261,7 +290,9 @@ func (s *Scanner) Scan() (pos token.Pos, tok token.Token, lit string) {
 				lit = "||"
 				s.next()
 			} else {
-				s.panic("Expecting another one |")
+				s.error(offs, "expected another one |")
+				tok = token.CON
+				lit = "|"
 			}
 		case '(':
 			tok = token.LPAREN
@@ -318,6 +349,21 @@ func (s *Scanner) Scan() (pos token.Pos, tok token.Token, lit string) {
 		case '$':
 			tok = token.DOLLAR
 			lit = "$"
+		case '!':
+			if s.ch == '=' {
+				tok = token.NEQ
+				lit = "!="
+				s.next()
+			} else {
+				tok = token.ILLEGAL
+				lit = "!"
+			}
+		default:
+			// Characters like @ in database links and & in
+			// SQL*Plus substitution variables aren't errors,
+			// they are kept in the text of defaults and queries
+			tok = token.ILLEGAL
+			lit = string(ch)
 		}
 	}
 
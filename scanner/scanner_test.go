@@ -0,0 +1,63 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scanner
+
+import (
+	"github.com/cyevgeniy/pldoc/token"
+	"testing"
+)
+
+type scanned struct {
+	tok token.Token
+	lit string
+}
+
+var scanSrc = []struct {
+	src    string
+	tokens []scanned
+}{
+	{
+		"v$session",
+		[]scanned{{token.IDENT, "v$session"}},
+	},
+	{
+		"a#b != 1",
+		[]scanned{{token.IDENT, "a#b"}, {token.NEQ, "!="}, {token.NUMBER, "1"}},
+	},
+	{
+		"t@dblink",
+		[]scanned{{token.IDENT, "t"}, {token.ILLEGAL, "@"}, {token.IDENT, "dblink"}},
+	},
+	{
+		"&def_val",
+		[]scanned{{token.ILLEGAL, "&"}, {token.IDENT, "def_val"}},
+	},
+}
+
+func TestScan(t *testing.T) {
+	for i := range scanSrc {
+		src := []byte(scanSrc[i].src)
+		file := token.NewFileSet().AddFile("testfile", -1, len(src))
+
+		var errs ErrorList
+		var s Scanner
+		s.Init(file, src, func(pos token.Position, msg string) { errs.Add(pos, msg) })
+
+		for _, want := range scanSrc[i].tokens {
+			_, tok, lit := s.Scan()
+			if tok != want.tok || lit != want.lit {
+				t.Fatalf("Scan exception. Expected: %s %q; Got: %s %q; Testcase #%d", want.tok, want.lit, tok, lit, i)
+			}
+		}
+
+		if _, tok, _ := s.Scan(); tok != token.EOF {
+			t.Fatalf("Scan exception. Expected EOF; Got: %s; Testcase #%d", tok, i)
+		}
+
+		if len(errs) > 0 {
+			t.Fatalf("Scan exception. Unexpected errors: %s; Testcase #%d", errs, i)
+		}
+	}
+}
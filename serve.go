@@ -0,0 +1,205 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/cyevgeniy/pldoc/scanner"
+	"github.com/cyevgeniy/pldoc/template"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The path of the Server-Sent Events stream that
+// tells open pages to reload when the sources change
+const reloadPath = "/_pldoc/reload"
+
+// Connects the page to the reload stream. It's added to
+// every served HTML page before the closing body tag.
+const reloadScript = `<script>new EventSource("` + reloadPath + `").onmessage = function() { location.reload(); };</script>`
+
+// Serves the documentation from memory. The site is replaced
+// when the sources change, and open pages are reloaded.
+type server struct {
+	mu      sync.RWMutex
+	site    *template.Site
+	changed chan struct{} // closed when the site is replaced
+}
+
+func newServer(site *template.Site) *server {
+	return &server{site: site, changed: make(chan struct{})}
+}
+
+// Replaces the site and tells open pages to reload
+func (s *server) reload(site *template.Site) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.site = site
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == reloadPath {
+		s.events(w, r)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+
+	s.mu.RLock()
+	site := s.site
+	s.mu.RUnlock()
+
+	var b bytes.Buffer
+	if err := site.Render(&b, name); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.NotFound(w, r)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	data := b.Bytes()
+	if strings.HasSuffix(name, ".html") {
+		data = bytes.Replace(data, []byte("</body>"), []byte(reloadScript+"\n</body>"), 1)
+	}
+
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(name)))
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(data)
+}
+
+// Streams an event every time the site is replaced,
+// until the page is closed
+func (s *server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// The channel is taken before the page is told that it's
+	// connected, so the site that is replaced right after that
+	// isn't missed
+	changed := s.changedChan()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-changed:
+			changed = s.changedChan()
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *server) changedChan() chan struct{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.changed
+}
+
+// Prints the syntax errors and warnings of the site's sources
+func printProblems(errs error, warnings scanner.ErrorList) {
+	if errs != nil {
+		scanner.PrintError(os.Stderr, errs)
+	}
+
+	printWarnings(warnings)
+}
+
+// Runs the serve command, which serves the documentation
+// and regenerates it when the sources change:
+//
+//	pldoc serve [-addr=:6060] [-ext=pks] [-tags] source_directory...
+func serveMain(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: pldoc serve [flags] source_directory...")
+		flags.PrintDefaults()
+	}
+
+	var addr = flags.String("addr", ":6060", "The address to listen on")
+	var ext = flags.String("ext", "pks", "The extension of specification files")
+	var tags = flags.Bool("tags", false, "Show @param, @return and other tags of doc comments in their own sections")
+	var interval = flags.Duration("interval", time.Second, "How often the sources are checked for changes")
+	var jobs = flags.Int("j", runtime.NumCPU(), "The number of files parsed in parallel")
+
+	flags.Parse(args)
+
+	dirs := flags.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	src := newSources(dirs, *ext, *jobs)
+	if _, err := src.update(); err != nil {
+		log.Fatal(err)
+	}
+
+	build := func() (*template.Site, error) {
+		f, errs := src.parsed()
+
+		var warnings scanner.ErrorList
+		site, err := template.NewSite(f, template.Options{
+			Paths:    src.paths,
+			Tags:     *tags,
+			Warnings: &warnings,
+		})
+
+		if err == nil {
+			printProblems(errs, warnings)
+		}
+
+		return site, err
+	}
+
+	site, err := build()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	srv := newServer(site)
+
+	go src.watch(*interval, func(changed []string) {
+		log.Printf("%d file(s) changed, regenerating", len(changed))
+
+		site, err := build()
+		if err != nil {
+			log.Print(err)
+			return
+		}
+
+		srv.reload(site)
+	}, func(err error) {
+		log.Print(err)
+	})
+
+	log.Printf("serving documentation on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, srv))
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/cyevgeniy/pldoc/ast"
+	"github.com/cyevgeniy/pldoc/scanner"
+	"github.com/cyevgeniy/pldoc/token"
+	"os"
+	"time"
+)
+
+// A parsed source file and the state of the file
+// when it was parsed
+type sourceFile struct {
+	modTime time.Time
+	size    int64
+	file    *ast.File // nil if the file couldn't be read
+	errs    scanner.ErrorList
+}
+
+// Source files that are kept parsed between changes, so only
+// new and changed files are parsed again. All files share the
+// file set, which grows with every parsed file.
+type sources struct {
+	dirs  []string
+	ext   string
+	jobs  int // the number of files parsed in parallel
+	fset  *token.FileSet
+	names []string // in the order they are found in the directories
+	paths map[string]string
+	files map[string]*sourceFile
+}
+
+func newSources(dirs []string, ext string, jobs int) *sources {
+	return &sources{
+		dirs:  dirs,
+		ext:   ext,
+		jobs:  jobs,
+		fset:  token.NewFileSet(),
+		files: make(map[string]*sourceFile),
+	}
+}
+
+// Walks the directories again and parses new and changed files.
+// Returns the names of the files that have been added, changed
+// or removed since the last update.
+func (s *sources) update() ([]string, error) {
+	names, paths, err := findFiles(s.dirs, s.ext)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	var infos []os.FileInfo
+	files := make(map[string]*sourceFile)
+
+	for _, name := range names {
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if sf, ok := s.files[name]; ok && sf.modTime.Equal(info.ModTime()) && sf.size == info.Size() {
+			files[name] = sf
+			continue
+		}
+
+		changed = append(changed, name)
+		infos = append(infos, info)
+	}
+
+	for i, r := range parseFiles(s.fset, changed, s.jobs) {
+		files[changed[i]] = &sourceFile{
+			modTime: infos[i].ModTime(),
+			size:    infos[i].Size(),
+			file:    r.file,
+			errs:    r.errs,
+		}
+	}
+
+	for name := range s.files {
+		if _, ok := files[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+
+	s.names, s.paths, s.files = names, paths, files
+
+	return changed, nil
+}
+
+// Returns the parsed files and their errors. Files
+// that couldn't be read are reported in the errors.
+func (s *sources) parsed() (*ast.Files, error) {
+	res := &ast.Files{
+		Description: "Documentation",
+		FileSet:     s.fset,
+	}
+
+	var errs scanner.ErrorList
+	for _, name := range s.names {
+		sf := s.files[name]
+		if sf.file != nil {
+			res.Add(sf.file)
+		}
+		errs = append(errs, sf.errs...)
+	}
+
+	return res, errs.Err()
+}
+
+// Checks the sources for changes every interval and calls
+// onChange with the names of changed files. Errors are passed
+// to onError, and the sources are checked again after the interval.
+func (s *sources) watch(interval time.Duration, onChange func(changed []string), onError func(err error)) {
+	for range time.Tick(interval) {
+		changed, err := s.update()
+		if err != nil {
+			onError(err)
+			continue
+		}
+
+		if len(changed) > 0 {
+			onChange(changed)
+		}
+	}
+}
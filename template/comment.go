@@ -0,0 +1,108 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"github.com/cyevgeniy/pldoc/ast"
+	"github.com/cyevgeniy/pldoc/doc"
+	"github.com/cyevgeniy/pldoc/token"
+	"html/template"
+	"strconv"
+	"strings"
+)
+
+// Formats the comment as HTML. Doc links like [pck.name]
+// become links to the declarations if r is set.
+func (r *crossRefs) formatComment(cg *ast.CommentGroup) template.HTML {
+	if cg == nil {
+		return ""
+	}
+
+	return r.formatText(cg.Text(), cg.Start())
+}
+
+// Formats the comment's text like formatComment.
+// Doc links are resolved at the position pos.
+func (r *crossRefs) formatText(text string, pos token.Pos) template.HTML {
+	var res []string
+
+	for _, b := range doc.Parse(text).Blocks {
+		switch b := b.(type) {
+		case *doc.Paragraph:
+			res = append(res, "<p>"+r.textHTML(b.Text, pos)+"</p>")
+		case *doc.Heading:
+			res = append(res, `<h5 class="commentHeading">`+r.textHTML(b.Text, pos)+"</h5>")
+		case *doc.Code:
+			res = append(res, "<pre>"+template.HTMLEscapeString(b.Text)+"</pre>")
+		case *doc.List:
+			tag := "ul"
+			if b.Ordered {
+				tag = "ol"
+			}
+
+			res = append(res, "<"+tag+">")
+			for _, item := range b.Items {
+				li := "<li>"
+				if item.Number != "" {
+					if n, err := strconv.Atoi(item.Number); err == nil {
+						li = `<li value="` + strconv.Itoa(n) + `">`
+					}
+				}
+				res = append(res, li+r.textHTML(item.Text, pos)+"</li>")
+			}
+			res = append(res, "</"+tag+">")
+		}
+	}
+
+	return template.HTML(strings.Join(res, "\n"))
+}
+
+// Returns the text as HTML. Doc links that can't be
+// resolved are left as they are, with the brackets.
+func (r *crossRefs) textHTML(text []doc.Text, pos token.Pos) string {
+	var b strings.Builder
+
+	for _, t := range text {
+		switch t := t.(type) {
+		case doc.Plain:
+			b.WriteString(template.HTMLEscapeString(string(t)))
+		case doc.CodeSpan:
+			b.WriteString("<code>" + template.HTMLEscapeString(string(t)) + "</code>")
+		case *doc.Link:
+			url := template.HTMLEscapeString(t.URL)
+			b.WriteString(`<a href="` + url + `">` + url + `</a>`)
+		case *doc.DocLink:
+			if url, ok := r.resolveLink(t.Name, pos); ok {
+				b.WriteString(`<a href="` + template.HTMLEscapeString(url) + `">` + template.HTMLEscapeString(t.Name) + `</a>`)
+			} else {
+				b.WriteString(template.HTMLEscapeString("[" + t.Name + "]"))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// Returns the URL of the doc link's declaration.
+// Without refs, no links are resolved.
+func (r *crossRefs) resolveLink(name string, pos token.Pos) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+
+	return r.resolveDecl(name, pos)
+}
+
+// Returns the fields if at least one of them has a doc,
+// so their descriptions are shown in a table
+func fieldDocs(list []*ast.Field) []*ast.Field {
+	for _, f := range list {
+		if f.Doc != nil {
+			return list
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,372 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"github.com/cyevgeniy/pldoc/ast"
+	"html/template"
+	"strings"
+)
+
+// Kinds of listing's parts
+type partKind byte
+
+const (
+	partText    partKind = iota // keywords and source text
+	partComment                 // a line of a field's doc, without the dashes
+	partTypeRef                 // a type name that links to its declaration
+)
+
+type listingPart struct {
+	kind partKind
+	text string
+	url  string // only for type references
+}
+
+// Listing of a declaration, like a function's signature.
+// Listings are built from parts rather than from HTML
+// strings, so all text that comes from the sources is
+// escaped in one place, when the listing is rendered.
+type listing struct {
+	parts []listingPart
+}
+
+func (l *listing) text(s ...string) {
+	for i := range s {
+		if s[i] != "" {
+			l.parts = append(l.parts, listingPart{kind: partText, text: s[i]})
+		}
+	}
+}
+
+func (l *listing) comment(s string) {
+	l.parts = append(l.parts, listingPart{kind: partComment, text: s})
+}
+
+func (l *listing) typeRef(s string, url string) {
+	l.parts = append(l.parts, listingPart{kind: partTypeRef, text: s, url: url})
+}
+
+// Returns the listing as plain text
+func (l *listing) String() string {
+	var b strings.Builder
+
+	for _, part := range l.parts {
+		if part.kind == partComment {
+			b.WriteString("-- ")
+		}
+		b.WriteString(part.text)
+	}
+
+	return b.String()
+}
+
+// Returns the listing as HTML. Comments are highlighted
+// and type references become links.
+func (l *listing) HTML() template.HTML {
+	var b strings.Builder
+
+	for _, part := range l.parts {
+		text := template.HTMLEscapeString(part.text)
+
+		switch part.kind {
+		case partComment:
+			b.WriteString(`<span class="srcComment">-- ` + text + `</span>`)
+		case partTypeRef:
+			b.WriteString(`<a href="` + template.HTMLEscapeString(part.url) + `" class="typeRef">` + text + `</a>`)
+		default:
+			b.WriteString(text)
+		}
+	}
+
+	return template.HTML(b.String())
+}
+
+// Builds listings of declarations. If refs is set,
+// type names link to their declarations.
+type lister struct {
+	refs *crossRefs
+}
+
+// Builds listings without links, for the text outputs
+var textLister = lister{}
+
+func (ls lister) typeName(l *listing, t *ast.Ident) {
+	if t == nil {
+		return
+	}
+
+	if ls.refs == nil {
+		l.text(t.Name)
+		return
+	}
+
+	prev := 0
+	for _, loc := range typeNames(t.Name) {
+		name := t.Name[loc[0]:loc[1]]
+		if url, ok := ls.refs.resolveType(name, t.Start()); ok {
+			l.text(t.Name[prev:loc[0]])
+			l.typeRef(name, url)
+			prev = loc[1]
+		}
+	}
+
+	l.text(t.Name[prev:])
+}
+
+func (ls lister) funcListing(fd *ast.FuncSpec) template.HTML {
+	return ls.funcSignature(fd).HTML()
+}
+
+func (ls lister) funcSignature(fd *ast.FuncSpec) *listing {
+	l := &listing{}
+	ls.writeFunc(l, fd)
+
+	return l
+}
+
+func (ls lister) writeFunc(l *listing, fd *ast.FuncSpec) {
+	l.text(funcHeader(fd))
+
+	if fd.Name != nil && fd.Name.Name != "" {
+		l.text(" ", fd.Name.Name)
+	}
+
+	ls.writeFieldList(l, fd.Params, true)
+
+	if fd.Ftype == ast.FtFunc {
+		l.text(" return ")
+		ls.typeName(l, fd.T)
+	}
+
+	ls.writeFuncOpts(l, fd)
+}
+
+// Writes function's clauses, like deterministic or
+// result_cache, as they are written in the specification
+func (ls lister) writeFuncOpts(l *listing, fd *ast.FuncSpec) {
+	if fd.Deterministic {
+		l.text(" deterministic")
+	}
+
+	if fd.Pipelined {
+		l.text(" pipelined")
+		if fd.PipelinedUsing != nil {
+			l.text(" using ")
+			ls.typeName(l, fd.PipelinedUsing)
+		}
+	}
+
+	if fd.ParallelEnable {
+		l.text(" parallel_enable")
+		if fd.Partition != nil {
+			l.text(" (", fd.Partition.Name, ")")
+		}
+	}
+
+	if fd.ResultCache {
+		l.text(" result_cache")
+		if len(fd.ReliesOn) > 0 {
+			l.text(" relies_on (", identList(fd.ReliesOn), ")")
+		}
+	}
+
+	if fd.AggregateUsing != nil {
+		l.text(" aggregate using ")
+		ls.typeName(l, fd.AggregateUsing)
+	}
+
+	if fd.SQLMacro != nil {
+		l.text(" sql_macro(", fd.SQLMacro.Name, ")")
+	}
+
+	if len(fd.AccessibleBy) > 0 {
+		l.text(" accessible by (", accessorList(fd.AccessibleBy), ")")
+	}
+}
+
+// Writes the list one field per line. If docs is set, each field
+// is preceded by the lines of its doc. Docs of record fields and
+// attributes are shown in their own table, so they are omitted.
+func (ls lister) writeFieldList(l *listing, fl *ast.FieldList, docs bool) {
+	if fl == nil || fl.List == nil {
+		return
+	}
+
+	l.text("(\n")
+	for i, field := range fl.List {
+		if docs && field.Doc != nil {
+			for _, c := range strings.Split(field.Doc.Text(), "\n") {
+				if len(c) > 0 {
+					l.text("    ")
+					l.comment(c)
+					l.text("\n")
+				}
+			}
+		}
+
+		l.text("    ")
+		ls.writeField(l, field)
+		if i < len(fl.List)-1 {
+			l.text(",")
+		}
+
+		l.text("\n")
+	}
+	l.text(")")
+}
+
+// Writes a parameter, a record field or an attribute
+// like ast.Field.String does
+func (ls lister) writeField(l *listing, f *ast.Field) {
+	if f.Kind != ast.VPar || f.Name == nil || f.T == nil {
+		l.text(f.String())
+		return
+	}
+
+	l.text(f.Name.Name)
+	if f.Mod != ast.ModNone {
+		l.text(" ", f.Mod.String())
+	}
+
+	if f.NoCopy {
+		l.text(" nocopy")
+	}
+
+	l.text(" ")
+	ls.typeName(l, f.T)
+	if f.Def != nil {
+		l.text(" default ", f.Def.Name)
+	}
+}
+
+func (ls lister) fieldTypeListing(f *ast.Field) template.HTML {
+	return ls.fieldType(f).HTML()
+}
+
+// Returns the parameter's mode and type, like "in out number",
+// or the type of a record field or an attribute
+func (ls lister) fieldType(f *ast.Field) *listing {
+	l := &listing{}
+
+	if f.Mod != ast.ModNone {
+		l.text(f.Mod.String(), " ")
+	}
+
+	if f.NoCopy {
+		l.text("nocopy ")
+	}
+
+	ls.typeName(l, f.T)
+
+	return l
+}
+
+func (ls lister) typeListing(td *ast.TypeDecl) template.HTML {
+	return ls.typeSignature(td).HTML()
+}
+
+func (ls lister) typeSignature(td *ast.TypeDecl) *listing {
+	l := &listing{}
+
+	l.text("type ", td.Name.Name, " is ", typeHeader(td))
+	ls.writeFieldList(l, td.Params, false)
+
+	if (td.Kind == ast.TkVarray || td.Kind == ast.TkTable) && td.T != nil {
+		l.text(" of ")
+		ls.typeName(l, td.T)
+	} else if td.Kind == ast.TkRefCursor && td.T != nil {
+		l.text(" return ")
+		ls.typeName(l, td.T)
+	}
+
+	return l
+}
+
+func (ls lister) subtypeListing(sd *ast.SubtypeDecl) template.HTML {
+	return ls.subtypeSignature(sd).HTML()
+}
+
+func (ls lister) subtypeSignature(sd *ast.SubtypeDecl) *listing {
+	l := &listing{}
+
+	l.text("subtype ", sd.Name.Name, " is ")
+	ls.typeName(l, sd.Base)
+
+	if sd.Range != nil {
+		l.text(" range ", sd.Range.Name)
+	}
+
+	if sd.NotNull {
+		l.text(" not null")
+	}
+
+	return l
+}
+
+func (ls lister) cursorListing(cd *ast.CursorDecl) template.HTML {
+	return ls.cursorSignature(cd).HTML()
+}
+
+func (ls lister) cursorSignature(cd *ast.CursorDecl) *listing {
+	l := &listing{}
+
+	l.text("cursor ", cd.Name.Name)
+	ls.writeFieldList(l, cd.Params, true)
+
+	if cd.T != nil {
+		l.text(" return ")
+		ls.typeName(l, cd.T)
+	}
+
+	l.text(" is\n", cd.SQL.Text)
+
+	return l
+}
+
+func (ls lister) methodListing(m *ast.Method) template.HTML {
+	return ls.methodSignature(m).HTML()
+}
+
+func (ls lister) methodSignature(m *ast.Method) *listing {
+	l := &listing{}
+
+	l.text(methodModifiers(m))
+	ls.writeFunc(l, m.Spec)
+
+	return l
+}
+
+func (ls lister) objectTypeListing(t *ast.ObjectType) template.HTML {
+	return ls.objectTypeSignature(t).HTML()
+}
+
+func (ls lister) objectTypeSignature(t *ast.ObjectType) *listing {
+	l := &listing{}
+
+	l.text("type ", t.Name.Name)
+
+	if t.Super != nil {
+		l.text(" under ")
+		ls.typeName(l, t.Super)
+	} else {
+		l.text(" as object")
+	}
+
+	if len(t.Attrs) > 0 {
+		l.text(" ")
+		ls.writeFieldList(l, &ast.FieldList{List: t.Attrs}, false)
+	}
+
+	if !t.Final {
+		l.text(" not final")
+	}
+
+	if !t.Instantiable {
+		l.text(" not instantiable")
+	}
+
+	return l
+}
@@ -0,0 +1,524 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"github.com/cyevgeniy/pldoc/ast"
+	"github.com/cyevgeniy/pldoc/doc"
+	"github.com/cyevgeniy/pldoc/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Markdown pages have the same names as the HTML pages,
+// but with the .md extension
+const (
+	mdIndexPage       = "index.md"
+	mdSubprogramsPage = "standalone-subprograms.md"
+)
+
+// Writes Markdown pages into the builder. Signatures are
+// written in fenced sql blocks, comments are split into
+// paragraphs and preformatted blocks like in the HTML output.
+type mdWriter struct {
+	b     strings.Builder
+	links *sourceLinker
+	refs  *crossRefs // resolves doc links in comments
+	tags  bool       // write tags of subprograms' doc comments in their own sections
+}
+
+func (w *mdWriter) line(s ...string) {
+	for i := range s {
+		w.b.WriteString(s[i])
+	}
+	w.b.WriteString("\n")
+}
+
+// Writes a paragraph, which is followed by an empty line
+func (w *mdWriter) para(s ...string) {
+	w.line(s...)
+	w.line()
+}
+
+// Writes the text as a fenced code block. The fence is
+// longer than any backtick sequence in the text.
+func (w *mdWriter) code(lang string, text string) {
+	n, run := 3, 0
+	for i := range text {
+		if text[i] == '`' {
+			run++
+			if run >= n {
+				n = run + 1
+			}
+		} else {
+			run = 0
+		}
+	}
+
+	fence := strings.Repeat("`", n)
+	w.line(fence, lang)
+	w.line(strings.TrimRight(text, "\n"))
+	w.para(fence)
+}
+
+func (w *mdWriter) comment(cg *ast.CommentGroup) {
+	if cg != nil {
+		w.text(cg.Text(), cg.Start())
+	}
+}
+
+// Writes the comment's text. Doc links are resolved at
+// the position pos and link to the declarations' pages.
+func (w *mdWriter) text(text string, pos token.Pos) {
+	for _, b := range doc.Parse(text).Blocks {
+		switch b := b.(type) {
+		case *doc.Paragraph:
+			w.para(w.inline(b.Text, pos))
+		case *doc.Heading:
+			w.para("#### ", w.inline(b.Text, pos))
+		case *doc.Code:
+			w.code("", b.Text)
+		case *doc.List:
+			for i, item := range b.Items {
+				marker := "- "
+				if b.Ordered {
+					marker = item.Number + ". "
+					if item.Number == "" {
+						marker = strconv.Itoa(i+1) + ". "
+					}
+				}
+
+				// Continuation lines are indented to belong to the item
+				text := strings.ReplaceAll(w.inline(item.Text, pos), "\n", "\n"+strings.Repeat(" ", len(marker)))
+				w.line(marker, text)
+			}
+			w.line()
+		}
+	}
+}
+
+// Returns the text as Markdown
+func (w *mdWriter) inline(text []doc.Text, pos token.Pos) string {
+	var b strings.Builder
+
+	for _, t := range text {
+		switch t := t.(type) {
+		case doc.Plain:
+			lineStart := b.Len() == 0 || strings.HasSuffix(b.String(), "\n")
+			b.WriteString(mdEscape(string(t), lineStart))
+		case doc.CodeSpan:
+			b.WriteString("`" + string(t) + "`")
+		case *doc.Link:
+			b.WriteString("<" + t.URL + ">")
+		case *doc.DocLink:
+			name := mdEscape(t.Name, false)
+			if url, ok := w.refs.resolveLink(t.Name, pos); ok {
+				b.WriteString("[" + name + "](" + mdPage(url) + ")")
+			} else {
+				b.WriteString("\\[" + name + "\\]")
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// Ordered list markers, like "1." or "2)"
+var mdOrderedRe = regexp.MustCompile(`^[0-9]+[.)]( |\n|$)`)
+
+// Escapes the text, so raw HTML and characters like * or [
+// are shown as they are written instead of being treated as
+// markup. Underscores inside words, like in p_user_id, don't
+// start emphasis, so they are kept. With lineStart, the text
+// starts a line, where # or "- " would start a heading or a list.
+func mdEscape(s string, lineStart bool) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if c == '\n' {
+			b.WriteByte(c)
+			lineStart = true
+			continue
+		}
+
+		if lineStart {
+			if c == ' ' || c == '\t' {
+				b.WriteByte(c)
+				continue
+			}
+
+			lineStart = false
+
+			if loc := mdOrderedRe.FindStringIndex(s[i:]); loc != nil {
+				n := strings.IndexAny(s[i:], ".)")
+				b.WriteString(s[i:i+n] + "\\")
+				i += n
+				c = s[i]
+			} else if c == '#' || c == '=' || (c == '-' || c == '+') && (i+1 == len(s) || s[i+1] == ' ') {
+				b.WriteByte('\\')
+			}
+		}
+
+		switch c {
+		case '&':
+			b.WriteString("&amp;")
+		case '<':
+			b.WriteString("&lt;")
+		case '>':
+			b.WriteString("&gt;")
+		case '\\', '`', '*', '[', ']':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '_':
+			if i == 0 || i+1 == len(s) || !isWordByte(s[i-1]) || !isWordByte(s[i+1]) {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+// Reports whether the byte is a part of a word,
+// where underscores don't start emphasis
+func isWordByte(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// Returns the Markdown page of the HTML page's URL. Markdown
+// pages don't have anchors, so links lead to the pages.
+func mdPage(url string) string {
+	if i := strings.Index(url, "#"); i >= 0 {
+		url = url[:i]
+	}
+
+	return strings.TrimSuffix(url, ".html") + ".md"
+}
+
+// Escapes the text for a table's cell
+func mdCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
+
+// Joins the text's lines, so it fits into a table's cell
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Writes the table of record fields or attributes
+// with their docs, if at least one of them has a doc
+func (w *mdWriter) fieldTable(list []*ast.Field) {
+	list = fieldDocs(list)
+	if len(list) == 0 {
+		return
+	}
+
+	w.line("| Field | Type | Description |")
+	w.line("| --- | --- | --- |")
+	for _, f := range list {
+		typ := textLister.fieldType(f).String()
+		w.line("| `", mdCell(f.Name.Name), "` | `", mdCell(typ), "` | ", mdCell(oneLine(mdEscape(f.Doc.Text(), false))), " |")
+	}
+	w.line()
+}
+
+// Writes the subprogram's doc comment. If tags are enabled,
+// they are written in their own sections like in HTML pages.
+func (w *mdWriter) funcDoc(fd *ast.FuncSpec) {
+	if !w.tags {
+		w.comment(fd.Doc)
+		return
+	}
+
+	tags := doc.ParseTags(fd.Doc.Text())
+
+	if tag := tags.Find("deprecated"); tag != nil {
+		w.para("> **Deprecated.** ", mdEscape(tag.Text, false))
+	}
+
+	w.text(tags.Text, fd.Start())
+
+	if params := paramDocs(fd, tags); len(params) > 0 {
+		w.line("| Parameter | Type | Description |")
+		w.line("| --- | --- | --- |")
+		for _, p := range params {
+			typ := textLister.fieldType(p.Field).String()
+			w.line("| `", mdCell(p.Field.Name.Name), "` | `", mdCell(typ), "` | ", mdCell(oneLine(mdEscape(p.Text, false))), " |")
+		}
+		w.line()
+	}
+
+	for _, tag := range tags.List {
+		switch tag.Name {
+		case "return":
+			w.para("**Returns:** ", mdEscape(tag.Text, false))
+		case "throws":
+			w.para("**Throws:** ", joinText("`"+tag.Arg+"`", mdEscape(tag.Text, false)))
+		case "see":
+			w.para("**See also:** ", joinText("`"+tag.Arg+"`", mdEscape(tag.Text, false)))
+		case "since":
+			w.para("**Since:** ", mdEscape(tag.Text, false))
+		}
+	}
+}
+
+// Writes the link to the node's source, if there is one
+func (w *mdWriter) source(n ast.Node) {
+	if link := w.links.link(n); link != "" {
+		w.para("[source](", link, ")")
+	}
+}
+
+// Writes labels like deterministic or autonomous transaction
+func (w *mdWriter) badges(list []string) {
+	if len(list) == 0 {
+		return
+	}
+
+	labels := make([]string, len(list))
+	for i := range list {
+		labels[i] = "`" + list[i] + "`"
+	}
+
+	w.para(strings.Join(labels, " "))
+}
+
+func (w *mdWriter) deprecation(list []*ast.Pragma) {
+	pr := ast.FindPragma(list, "deprecate")
+	if pr == nil {
+		return
+	}
+
+	if len(pr.Args) > 1 {
+		w.para("> **Deprecated.** ", mdEscape(strings.Trim(pr.Args[1].Name, "'"), false))
+	} else {
+		w.para("> **Deprecated.**")
+	}
+}
+
+// Writes a group of overloaded subprograms. signature returns
+// the listing of the group's item.
+func (w *mdWriter) funcGroup(g *funcGroup, header string, signature func(funcItem) string) {
+	if len(g.Items) > 1 {
+		w.para("### ", g.Name, " (", strconv.Itoa(len(g.Items)), " overloads)")
+	} else {
+		w.para("### ", header, " ", g.Name)
+	}
+
+	for _, item := range g.Items {
+		w.code("sql", signature(item))
+		w.badges(funcBadges(item.Spec))
+		w.deprecation(item.Spec.Pragmas)
+		if item.Method != nil {
+			w.source(item.Method)
+		} else {
+			w.source(item.Spec)
+		}
+		w.funcDoc(item.Spec)
+	}
+}
+
+// Returns the summary of the comment that follows
+// a link in the index, or an empty string
+func summarySuffix(cg *ast.CommentGroup) string {
+	if s := summary(cg); s != "" {
+		return " — " + s
+	}
+
+	return ""
+}
+
+// Writes the list of packages, types and standalone subprograms
+func (w *mdWriter) index(pckList []*ast.Package, typeList []*ast.ObjectType, funcList []*ast.FuncSpec) {
+	w.para("# Documentation")
+
+	if len(pckList) > 0 {
+		w.para("## Packages")
+		for _, pck := range pckList {
+			w.line("- [", packageFullName(pck), "](", pck.Name.Name, ".md)", mdEscape(summarySuffix(pck.Doc), false))
+		}
+		w.line()
+	}
+
+	if len(typeList) > 0 {
+		w.para("## Types")
+		for _, t := range typeList {
+			w.line("- [", t.Name.Name, "](", t.Name.Name, ".md)", mdEscape(summarySuffix(t.Doc), false))
+		}
+		w.line()
+	}
+
+	if len(funcList) > 0 {
+		w.para("## [Standalone subprograms](", mdSubprogramsPage, ")")
+	}
+}
+
+func (w *mdWriter) pck(pck *ast.Package) {
+	w.para("[Index](", mdIndexPage, ")")
+	w.para("# Package ", packageFullName(pck))
+
+	clauses := packageClauses(pck)
+	for i := range clauses {
+		clauses[i] = "`" + clauses[i] + "`"
+	}
+	w.para(strings.Join(clauses, " "))
+
+	w.badges(pragmaBadges(pck.PackagePragmas()))
+	w.deprecation(pck.PackagePragmas())
+	w.source(pck)
+	w.comment(pck.Doc)
+
+	if len(pck.VarDecls) > 0 {
+		w.para("## Variables, constants")
+		for _, vd := range pck.VarDecls {
+			w.para("### ", varHeader(vd), " ", vd.Name.Name)
+			w.code("sql", vd.String())
+			if vd.Code != nil {
+				w.para("Oracle error code: `", vd.Code.Name, "`")
+			}
+			w.source(vd)
+			w.comment(vd.Doc)
+		}
+	}
+
+	if len(pck.FuncSpecs) > 0 {
+		w.para("## Functions, procedures")
+		for _, g := range funcGroups(pck.FuncSpecs) {
+			w.funcGroup(g, funcHeader(g.Items[0].Spec), func(item funcItem) string {
+				return textLister.funcSignature(item.Spec).String()
+			})
+		}
+	}
+
+	if len(pck.TypeDecls) > 0 {
+		w.para("## Types")
+		for _, td := range pck.TypeDecls {
+			w.para("### ", typeHeader(td), " ", td.Name.Name)
+			w.code("sql", textLister.typeSignature(td).String())
+			w.source(td)
+			w.comment(td.Doc)
+			if td.Params != nil {
+				w.fieldTable(td.Params.List)
+			}
+		}
+	}
+
+	if len(pck.SubtypeDecls) > 0 {
+		w.para("## Subtypes")
+		for _, sd := range pck.SubtypeDecls {
+			w.para("### subtype ", sd.Name.Name)
+			w.code("sql", textLister.subtypeSignature(sd).String())
+			w.source(sd)
+			w.comment(sd.Doc)
+		}
+	}
+
+	if len(pck.CursorDecls) > 0 {
+		w.para("## Cursors")
+		for _, cd := range pck.CursorDecls {
+			w.para("### cursor ", cd.Name.Name)
+			w.code("sql", textLister.cursorSignature(cd).String())
+			w.source(cd)
+			w.comment(cd.Doc)
+		}
+	}
+}
+
+func (w *mdWriter) objectType(t *ast.ObjectType) {
+	w.para("[Index](", mdIndexPage, ")")
+	w.para("# Type ", t.Name.Name)
+	w.source(t)
+	w.comment(t.Doc)
+	w.code("sql", textLister.objectTypeSignature(t).String())
+	w.fieldTable(t.Attrs)
+
+	if len(t.Methods) > 0 {
+		w.para("## Methods")
+		for _, g := range methodGroups(t.Methods) {
+			w.funcGroup(g, methodHeader(g.Items[0].Method), func(item funcItem) string {
+				return textLister.methodSignature(item.Method).String()
+			})
+		}
+	}
+}
+
+func (w *mdWriter) subprograms(list []*ast.FuncSpec) {
+	w.para("[Index](", mdIndexPage, ")")
+	w.para("# Standalone subprograms")
+
+	for _, g := range funcGroups(list) {
+		w.funcGroup(g, funcHeader(g.Items[0].Spec), func(item funcItem) string {
+			return textLister.funcSignature(item.Spec).String()
+		})
+	}
+}
+
+// Writes the page into the file. The trailing empty
+// line of the last paragraph is removed.
+func (w *mdWriter) writeFile(fname string) error {
+	text := strings.TrimRight(w.b.String(), "\n") + "\n"
+	return os.WriteFile(fname, []byte(text), 0666)
+}
+
+// Writes the documentation as Markdown files: one file per
+// package and object type, a file with standalone subprograms
+// and the index with links to all of them.
+func ExecuteMarkdown(dir string, f *ast.Files, opts Options) error {
+	err := os.Mkdir(dir, 0750)
+	if err != nil && !os.IsExist(err) {
+		return err
+	}
+
+	// There are no source pages in the Markdown output,
+	// so only the external repository browser is linked
+	links := &sourceLinker{fset: f.FileSet, opts: opts}
+
+	refs := newCrossRefs(f)
+	refs.checkDocLinks(f, opts.warn)
+
+	pckList := f.GetPackages()
+	typeList := f.GetTypes()
+	funcList := f.GetFuncs()
+
+	w := &mdWriter{links: links, refs: refs, tags: opts.Tags}
+	w.index(pckList, typeList, funcList)
+	if err = w.writeFile(filepath.Join(dir, mdIndexPage)); err != nil {
+		return err
+	}
+
+	if len(funcList) > 0 {
+		w = &mdWriter{links: links, refs: refs, tags: opts.Tags}
+		w.subprograms(funcList)
+		if err = w.writeFile(filepath.Join(dir, mdSubprogramsPage)); err != nil {
+			return err
+		}
+	}
+
+	for _, pck := range pckList {
+		w = &mdWriter{links: links, refs: refs, tags: opts.Tags}
+		w.pck(pck)
+		if err = w.writeFile(filepath.Join(dir, pck.Name.Name+".md")); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range typeList {
+		w = &mdWriter{links: links, refs: refs, tags: opts.Tags}
+		w.objectType(t)
+		if err = w.writeFile(filepath.Join(dir, t.Name.Name+".md")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
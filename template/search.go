@@ -0,0 +1,67 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"encoding/json"
+	"github.com/cyevgeniy/pldoc/ast"
+)
+
+// The search index is a script rather than a JSON file,
+// because browsers don't allow to fetch files when pages
+// are opened from the local file system
+const searchIndexFile = "search-index.js"
+
+// An entry of the search index. The "Quick jump" box shows
+// entries of other pages after the current page's items.
+type searchEntry struct {
+	Label string `json:"label"` // like "function get_user"
+	Page  string `json:"page"`  // name of the package or type, shown next to the label
+	URL   string `json:"url"`
+}
+
+// Returns entries for the page itself and for the jump items on it
+func pageEntries(label string, page string, url string, items []jumpItem) []searchEntry {
+	res := []searchEntry{{Label: label, Page: page, URL: url}}
+
+	for _, item := range items {
+		res = append(res, searchEntry{Label: item.Label, Page: page, URL: url + "#" + item.Anchor})
+	}
+
+	return res
+}
+
+// Returns the entries of all pages in the order
+// they are listed in the sidebar
+func searchEntries(f *ast.Files) []searchEntry {
+	res := make([]searchEntry, 0)
+
+	for _, pck := range f.GetPackages() {
+		name := pck.Name.Name
+		res = append(res, pageEntries("package "+name, name, name+".html", packageJumpItems(pck))...)
+	}
+
+	if funcList := f.GetFuncs(); len(funcList) > 0 {
+		res = append(res, pageEntries("standalone subprograms", "standalone", subprogramsPage, funcJumpItems(funcList))...)
+	}
+
+	for _, t := range f.GetTypes() {
+		name := t.Name.Name
+		res = append(res, pageEntries("type "+name, name, name+".html", typeJumpItems(t))...)
+	}
+
+	return res
+}
+
+// Returns the search index of all pages as a script
+// that defines the searchIndex variable
+func searchIndex(f *ast.Files) ([]byte, error) {
+	data, err := json.Marshal(searchEntries(f))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte("var searchIndex = " + string(data) + ";\n"), nil
+}
@@ -0,0 +1,332 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"github.com/cyevgeniy/pldoc/ast"
+	"github.com/cyevgeniy/pldoc/token"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// A file of the generated documentation. Pages are rendered
+// from templates, assets like main.css are written as they are.
+type page struct {
+	tmpl string // name of the template; empty for assets
+	data reportData
+	raw  []byte
+	file string // the source file that the page documents, if any
+
+	// The parsed file of a source page and the checksum of its
+	// source. The source is read and highlighted when the page
+	// is rendered.
+	src *token.File
+	sum [sha256.Size]byte
+}
+
+// Site is the HTML documentation of the parsed files. Its pages
+// are rendered on demand, so they can be written into a directory
+// or served from memory, and only the pages that are affected by
+// changes of the sources can be regenerated.
+type Site struct {
+	t     *template.Template
+	pages map[string]*page
+	names []string // in the order the pages are added
+	refs  *crossRefs
+}
+
+func (s *Site) add(name string, p *page) {
+	s.pages[name] = p
+	s.names = append(s.names, name)
+}
+
+// Prepares the documentation of the files. Source files that
+// don't exist, like files parsed from memory, get no source pages.
+// Sources are read and highlighted only when their pages are rendered.
+// Warnings about unresolved references are reported to opts.
+func NewSite(f *ast.Files, opts Options) (*Site, error) {
+	// Only files that have a source page are linked
+	links := &sourceLinker{
+		fset:  f.FileSet,
+		opts:  opts,
+		pages: make(map[string]string),
+	}
+
+	// Type names in listings link to the types' documentation
+	refs := newCrossRefs(f)
+	refs.checkTypes(f, opts.warn)
+	refs.checkDocLinks(f, opts.warn)
+	html := lister{refs: refs}
+
+	docs := &funcDocs{refs: refs, ls: html, tags: opts.Tags}
+	docs.check(f, opts.warn)
+
+	fm := template.FuncMap{
+		"varHeader":        varHeader,
+		"funcHeader":       funcHeader,
+		"funcListing":      html.funcListing,
+		"funcBadges":       funcBadges,
+		"pragmaBadges":     pragmaBadges,
+		"typeHeader":       typeHeader,
+		"typeListing":      html.typeListing,
+		"cursorListing":    html.cursorListing,
+		"subtypeListing":   html.subtypeListing,
+		"fieldTypeListing": html.fieldTypeListing,
+		"formatComment":    refs.formatComment,
+		"fieldDocs":        fieldDocs,
+		"funcDoc":          docs.funcDoc,
+
+		"deprecationBanner": deprecationBanner,
+		"funcGroups":        funcGroups,
+		"methodGroups":      methodGroups,
+		"packageJumpItems":  packageJumpItems,
+		"typeJumpItems":     typeJumpItems,
+		"funcJumpItems":     funcJumpItems,
+		"packageClauses":    packageClauses,
+		"packageFullName":   packageFullName,
+		"sourceLink":        links.link,
+		"summary":           summary,
+
+		"methodHeader":      methodHeader,
+		"methodListing":     html.methodListing,
+		"objectTypeListing": html.objectTypeListing,
+	}
+
+	t, err := template.New("Documentation").Funcs(fm).Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, named := range []struct{ name, text string }{
+		{"type", typeTmpl},
+		{"subprograms", subprogramsTmpl},
+		{"sidebar", sidebarTmpl},
+		{"fields", fieldsTmpl},
+		{"jump", jumpTmpl},
+		{"source", sourceTmpl},
+		{"index", indexTmpl},
+	} {
+		if _, err = t.New(named.name).Parse(named.text); err != nil {
+			return nil, err
+		}
+	}
+
+	index, err := searchIndex(f)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Site{t: t, pages: make(map[string]*page)}
+	s.add("main.css", &page{raw: css})
+	s.add("pldoc.js", &page{raw: js})
+	s.add(searchIndexFile, &page{raw: index})
+
+	pckList := f.GetPackages()
+	typeList := f.GetTypes()
+	funcList := f.GetFuncs()
+
+	// Source pages are added first, so the documentation
+	// pages know which files can be linked
+	for i := range f.Files {
+		fname := f.Files[i].Name
+
+		src := f.FileSet.File(f.Files[i].FileStart)
+		if src == nil {
+			continue
+		}
+
+		if _, err := os.Stat(fname); os.IsNotExist(err) {
+			// The file may have been parsed from memory
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		path, ok := opts.Paths[fname]
+		if !ok {
+			path = fname
+		}
+
+		// Files from different source directories
+		// may have the same relative paths
+		name := sourcePageName(path)
+		for n := 2; s.pages[name] != nil; n++ {
+			name = sourcePageName(path + "-" + strconv.Itoa(n))
+		}
+
+		s.add(name, &page{
+			tmpl: "source",
+			file: fname,
+			src:  src,
+			sum:  f.Files[i].Sum,
+			data: reportData{
+				PackageList: pckList,
+				TypeList:    typeList,
+				FuncList:    funcList,
+				SourceFile:  filepath.ToSlash(path),
+			},
+		})
+
+		links.pages[fname] = name
+	}
+
+	s.add(indexPage, &page{
+		tmpl: "index",
+		data: reportData{
+			PackageList: pckList,
+			TypeList:    typeList,
+			FuncList:    funcList,
+		},
+	})
+
+	if len(funcList) > 0 {
+		s.add(subprogramsPage, &page{
+			tmpl: "subprograms",
+			data: reportData{
+				PackageList: pckList,
+				TypeList:    typeList,
+				FuncList:    funcList,
+			},
+		})
+	}
+
+	for i := range f.Files {
+		for _, pck := range f.Files[i].Packages {
+			// A page for each pl/sql package
+			s.add(pck.Name.Name+".html", &page{
+				tmpl: "Documentation",
+				file: f.Files[i].Name,
+				data: reportData{
+					Package:     pck,
+					PackageList: pckList,
+					TypeList:    typeList,
+					FuncList:    funcList,
+				},
+			})
+		}
+
+		for _, typ := range f.Files[i].Types {
+			// Object types share the namespace with packages,
+			// so their pages are placed next to package pages
+			s.add(typ.Name.Name+".html", &page{
+				tmpl: "type",
+				file: f.Files[i].Name,
+				data: reportData{
+					Type:        typ,
+					PackageList: pckList,
+					TypeList:    typeList,
+					FuncList:    funcList,
+				},
+			})
+		}
+	}
+
+	s.refs = refs
+
+	return s, nil
+}
+
+// Reports whether the pages of unchanged files are the same on
+// both sites. Every page lists all pages in its sidebar, so the
+// list must be the same, as well as the targets of the links.
+func (s *Site) sameLayout(old *Site) bool {
+	if len(s.names) != len(old.names) {
+		return false
+	}
+
+	for i := range s.names {
+		if s.names[i] != old.names[i] {
+			return false
+		}
+	}
+
+	return s.refs.sameLinks(old.refs)
+}
+
+// Returns the pages that have to be regenerated when the files
+// have changed since the old site was generated. If pages were
+// added or removed, declarations moved, or added with the names of
+// unresolved references, all pages are returned.
+// Otherwise, only the pages of the changed files and the pages
+// that list all declarations with their docs are.
+func (s *Site) Affected(old *Site, changed []string) []string {
+	if old == nil || !s.sameLayout(old) {
+		return s.names
+	}
+
+	res := []string{indexPage, searchIndexFile}
+	if _, ok := s.pages[subprogramsPage]; ok {
+		res = append(res, subprogramsPage)
+	}
+
+	for _, fname := range changed {
+		res = append(res, s.FilePages(fname)...)
+	}
+
+	return res
+}
+
+// Returns the names of all pages, like "index.html" or "main.css"
+func (s *Site) Pages() []string {
+	return s.names
+}
+
+// Returns the names of the pages that document the declarations
+// of the source file, including the file's source page
+func (s *Site) FilePages(fname string) []string {
+	var res []string
+
+	for _, name := range s.names {
+		if s.pages[name].file == fname {
+			res = append(res, name)
+		}
+	}
+
+	return res
+}
+
+// Writes the page with the name. The error wraps os.ErrNotExist
+// if there is no such page.
+func (s *Site) Render(w io.Writer, name string) error {
+	p, ok := s.pages[name]
+	if !ok {
+		return fmt.Errorf("page %s: %w", name, os.ErrNotExist)
+	}
+
+	if p.tmpl == "" {
+		_, err := w.Write(p.raw)
+		return err
+	}
+
+	data := p.data
+	if p.src != nil {
+		src, err := os.ReadFile(p.file)
+		if err != nil {
+			return err
+		}
+
+		if data.Source, err = highlightSource(p.src, p.sum, src); err != nil {
+			return err
+		}
+	}
+
+	return s.t.ExecuteTemplate(w, p.tmpl, data)
+}
+
+// Renders the page with the name into the file in the directory
+func (s *Site) WritePage(dir string, name string) error {
+	var b bytes.Buffer
+	if err := s.Render(&b, name); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, name), b.Bytes(), 0666)
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/cyevgeniy/pldoc/scanner"
+	"github.com/cyevgeniy/pldoc/token"
+	"html/template"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Returns the name of the page with the source code of the file
+// at the path, which is relative to its source directory.
+// Directories are kept in the name, so files with the same name
+// in different directories don't clash. The hyphen guarantees
+// that the name doesn't clash with a package's page.
+func sourcePageName(fname string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+
+		return '_'
+	}, filepath.ToSlash(filepath.Clean(fname)))
+
+	return "source-" + name + ".html"
+}
+
+// Returns the CSS class of the token in the source view,
+// or an empty string if the token isn't highlighted
+func tokenClass(tok token.Token) string {
+	switch {
+	case tok == token.COMMENT:
+		return "srcComment"
+	case tok == token.STRING:
+		return "srcString"
+	case tok == token.NUMBER:
+		return "srcNumber"
+	case token.IsKeyword(tok):
+		return "srcKeyword"
+	}
+
+	return ""
+}
+
+// Renders the source code of the parsed file as HTML with syntax
+// highlighting. Each line of the file's line table is wrapped into
+// a span with the "L<line number>" id, so declarations can link to
+// their lines. The source must be the one the file was parsed from,
+// which is checked with the checksum of that source.
+func highlightSource(file *token.File, sum [sha256.Size]byte, src []byte) (template.HTML, error) {
+	if len(src) != file.Size() || sha256.Sum256(src) != sum {
+		return "", fmt.Errorf("%s has changed since it was parsed", file.Filename)
+	}
+
+	var s scanner.Scanner
+	s.Init(file, src, nil)
+
+	// The token that is highlighted next
+	var start, end int
+	class := ""
+	scan := func() bool {
+		for {
+			pos, tok, _ := s.Scan()
+			if tok == token.EOF {
+				return false
+			}
+
+			if class = tokenClass(tok); class != "" {
+				start, end = file.Offset(pos), s.Offset()
+				return true
+			}
+		}
+	}
+	more := scan()
+
+	var b strings.Builder
+	write := func(text []byte, class string) {
+		if len(text) == 0 {
+			return
+		}
+
+		if class != "" {
+			b.WriteString(`<span class="` + class + `">` + template.HTMLEscapeString(string(text)) + `</span>`)
+		} else {
+			b.WriteString(template.HTMLEscapeString(string(text)))
+		}
+	}
+
+	n := file.LineCount()
+	for line := 1; line <= n; line++ {
+		offs := file.Offset(file.LineStart(line))
+
+		// The newline doesn't belong to the line, and
+		// the final newline doesn't start a new line
+		lineEnd := len(src)
+		if line < n {
+			lineEnd = file.Offset(file.LineStart(line+1)) - 1
+		} else if lineEnd > offs && src[lineEnd-1] == '\n' {
+			lineEnd--
+		}
+
+		num := strconv.Itoa(line)
+		b.WriteString(`<span class="srcLine" id="L` + num + `"><a href="#L` + num + `" class="lineNum">` + num + `</a>`)
+
+		// Spans of multiline tokens, like comments, are closed
+		// at the end of each line and opened again on the next one
+		for offs < lineEnd {
+			for more && end <= offs {
+				more = scan()
+			}
+
+			if !more || start >= lineEnd {
+				write(src[offs:lineEnd], "")
+				break
+			}
+
+			if start > offs {
+				write(src[offs:start], "")
+				offs = start
+			}
+
+			e := end
+			if e > lineEnd {
+				e = lineEnd
+			}
+
+			write(src[offs:e], class)
+			offs = e
+		}
+
+		b.WriteString("</span>")
+		if line < n {
+			b.WriteString("\n")
+		}
+	}
+
+	return template.HTML(b.String()), nil
+}
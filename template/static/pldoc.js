@@ -6,6 +6,10 @@
 // box behaviour. It's inspired by the similar
 // search box as in the Go language's package documentation
 //
+// The box lists the items of the current page first, then
+// the items of all other pages from the search index, which
+// is defined in search-index.js.
+//
 // The search box is shown by pressing the 'f' key.
 // Arrow keys are used for moving between items in the list of
 // available items on the page. Pressing the 'Enter' key
@@ -27,6 +31,9 @@ let listWrapper = document.getElementById('list-wrap')
 // List of the "jump to" items
 var items = listWrapper.children
 
+// Name of the current page, like "api.html"
+let currentPage = location.pathname.split('/').pop() || 'index.html'
+
 let activeItemIdx = -1
 
 function isBoxVisible() {
@@ -141,8 +148,10 @@ function getActiveItem() {
     }
 }
 
-function jumpToAnchor(id) {
-    location.hash = id
+// Jumps to the item's link, which is either an anchor
+// on the current page or a link to another page
+function jumpToAnchor(href) {
+    location.href = href
 }
 
 function getVisibleItems() {
@@ -157,14 +166,14 @@ function activateItem(num) {
         return
     }
 
-    // Remove 'active-item' class from the previous active item
+    // Remove 'activeItem' class from the previous active item
     // if it differs from the activated item
     if (activeItemIdx != num && activeItemIdx >= 0) {
-        list[activeItemIdx].classList.remove('bg-cyan-700')
+        list[activeItemIdx].classList.remove('activeItem')
     }
 
     if (num >= 0 && num < list.length) {
-        list[num].classList.add('bg-cyan-700')
+        list[num].classList.add('activeItem')
 
         // scroll if current active element is outside visible rectangle
         if (list[num].getBoundingClientRect().bottom > listWrapper.getBoundingClientRect().bottom) {
@@ -185,7 +194,7 @@ function activateItem(num) {
     }
 }
 
-// Removes class 'active-item' from all items
+// Removes class 'activeItem' from all items
 // in the search box
 function removeActiveItems() {
     if (!items) {
@@ -193,7 +202,35 @@ function removeActiveItems() {
     }
 
     for (let item of items) {
-        item.classList.remove('bg-cyan-700')
+        item.classList.remove('activeItem')
+    }
+}
+
+// Adds entries of the search index that belong to other
+// pages after the items of the current page
+function addSearchIndexItems() {
+    if (typeof searchIndex === 'undefined') {
+        return
+    }
+
+    for (let entry of searchIndex) {
+        if (entry.url.split('#')[0] == currentPage) {
+            continue
+        }
+
+        let item = document.createElement('a')
+        item.href = entry.url
+        item.className = 'jumpItem'
+        item.textContent = entry.label
+
+        if (entry.page) {
+            let page = document.createElement('span')
+            page.className = 'jumpPage'
+            page.textContent = entry.page
+            item.appendChild(page)
+        }
+
+        listWrapper.appendChild(item)
     }
 }
 
@@ -217,4 +254,5 @@ function assignEventListeners() {
     }
 }
 
+addSearchIndexItems()
 assignEventListeners()
@@ -0,0 +1,183 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"github.com/cyevgeniy/pldoc/ast"
+	"github.com/cyevgeniy/pldoc/doc"
+	"github.com/cyevgeniy/pldoc/token"
+	"html/template"
+	"strings"
+)
+
+// Renders doc comments of subprograms. If tags are enabled,
+// @param, @return and other tags are shown in their own
+// sections instead of the comment's text.
+type funcDocs struct {
+	refs *crossRefs
+	ls   lister
+	tags bool
+}
+
+// A parameter with its description from the @param
+// tag or, if there is no such tag, from its own doc
+type paramDoc struct {
+	Field *ast.Field
+	Text  string
+}
+
+// Returns the parameters of the subprogram, if at least
+// one of them is described with a @param tag
+func paramDocs(fd *ast.FuncSpec, tags *doc.Tags) []paramDoc {
+	if fd.Params == nil || len(tags.Lookup("param")) == 0 {
+		return nil
+	}
+
+	var res []paramDoc
+	for _, f := range fd.Params.List {
+		text := strings.TrimSpace(f.Doc.Text())
+		if tag := tags.Param(f.Name.Name); tag != nil {
+			text = tag.Text
+		}
+
+		res = append(res, paramDoc{Field: f, Text: text})
+	}
+
+	return res
+}
+
+// Returns the link to the declaration, or the
+// escaped name if it can't be resolved
+func (d *funcDocs) declLink(name string, pos token.Pos) string {
+	if url, ok := d.refs.resolveDecl(name, pos); ok {
+		return `<a href="` + template.HTMLEscapeString(url) + `"><code>` +
+			template.HTMLEscapeString(name) + `</code></a>`
+	}
+
+	return "<code>" + template.HTMLEscapeString(name) + "</code>"
+}
+
+func (d *funcDocs) funcDoc(fd *ast.FuncSpec) template.HTML {
+	if !d.tags {
+		return d.refs.formatComment(fd.Doc)
+	}
+
+	tags := doc.ParseTags(fd.Doc.Text())
+	var b strings.Builder
+
+	if tag := tags.Find("deprecated"); tag != nil {
+		b.WriteString(`<div class="deprecated"><b>Deprecated.</b> ` + template.HTMLEscapeString(tag.Text) + "</div>\n")
+	}
+
+	b.WriteString(string(d.refs.formatText(tags.Text, fd.Start())))
+
+	if params := paramDocs(fd, tags); len(params) > 0 {
+		b.WriteString("\n<table class=\"params\">\n<tr><th>Parameter</th><th>Type</th><th>Description</th></tr>\n")
+		for _, p := range params {
+			b.WriteString("<tr><td><code>" + template.HTMLEscapeString(p.Field.Name.Name) + "</code></td>" +
+				"<td><code>" + string(d.ls.fieldTypeListing(p.Field)) + "</code></td>" +
+				"<td>" + string(d.refs.formatText(p.Text, fd.Start())) + "</td></tr>\n")
+		}
+		b.WriteString("</table>")
+	}
+
+	var dl []string
+	for _, tag := range tags.List {
+		switch tag.Name {
+		case "return":
+			dl = append(dl, "<dt>Returns</dt><dd>"+template.HTMLEscapeString(tag.Text)+"</dd>")
+		case "throws":
+			dl = append(dl, "<dt>Throws</dt><dd>"+joinText(d.declLink(tag.Arg, fd.Start()), template.HTMLEscapeString(tag.Text))+"</dd>")
+		case "see":
+			dl = append(dl, "<dt>See also</dt><dd>"+joinText(d.declLink(tag.Arg, fd.Start()), template.HTMLEscapeString(tag.Text))+"</dd>")
+		case "since":
+			dl = append(dl, "<dt>Since</dt><dd>"+template.HTMLEscapeString(tag.Text)+"</dd>")
+		}
+	}
+
+	if len(dl) > 0 {
+		b.WriteString("\n<dl class=\"docTags\">\n" + strings.Join(dl, "\n") + "\n</dl>")
+	}
+
+	return template.HTML(b.String())
+}
+
+// Reports @param tags for parameters that the subprograms
+// don't have and @see references that can't be resolved.
+// Unresolved @throws references are only recorded.
+func (d *funcDocs) check(f *ast.Files, warn func(pos token.Position, msg string)) {
+	if !d.tags {
+		return
+	}
+
+	checkFunc := func(fd *ast.FuncSpec) {
+		if fd.Doc == nil {
+			return
+		}
+
+		pos := f.FileSet.Position(fd.Doc.Start())
+		tags := doc.ParseTags(fd.Doc.Text())
+
+		for _, tag := range tags.Lookup("param") {
+			if !hasParam(fd, tag.Arg) {
+				warn(pos, "@param "+tag.Arg+": "+funcHeader(fd)+" "+fd.Name.Name+" has no such parameter")
+			}
+		}
+
+		for _, tag := range tags.Lookup("see") {
+			if _, ok := d.refs.resolveDecl(tag.Arg, fd.Start()); !ok {
+				d.refs.miss(tag.Arg)
+				warn(pos, "unresolved @see reference "+tag.Arg)
+			}
+		}
+
+		// Exceptions may be predefined, like no_data_found,
+		// so they aren't reported
+		for _, tag := range tags.Lookup("throws") {
+			if _, ok := d.refs.resolveDecl(tag.Arg, fd.Start()); !ok {
+				d.refs.miss(tag.Arg)
+			}
+		}
+	}
+
+	for _, pck := range f.GetPackages() {
+		for _, fd := range pck.FuncSpecs {
+			checkFunc(fd)
+		}
+	}
+
+	for _, t := range f.GetTypes() {
+		for _, m := range t.Methods {
+			checkFunc(m.Spec)
+		}
+	}
+
+	for _, fd := range f.GetFuncs() {
+		checkFunc(fd)
+	}
+}
+
+// Joins the tag's argument with its text, if there is any
+func joinText(arg string, text string) string {
+	if text == "" {
+		return arg
+	}
+
+	return arg + " " + text
+}
+
+func hasParam(fd *ast.FuncSpec, name string) bool {
+	if fd.Params == nil {
+		return false
+	}
+
+	for _, f := range fd.Params.List {
+		if strings.EqualFold(f.Name.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
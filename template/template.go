@@ -7,16 +7,43 @@ package template
 import (
 	_ "embed"
 	"github.com/cyevgeniy/pldoc/ast"
+	"github.com/cyevgeniy/pldoc/doc"
+	"github.com/cyevgeniy/pldoc/scanner"
+	"github.com/cyevgeniy/pldoc/token"
 	"html/template"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 var (
 	//go:embed static/single.html
 	tmpl string
 
+	//go:embed static/type.html
+	typeTmpl string
+
+	//go:embed static/subprograms.html
+	subprogramsTmpl string
+
+	//go:embed static/sidebar.html
+	sidebarTmpl string
+
+	//go:embed static/jump.html
+	jumpTmpl string
+
+	//go:embed static/fields.html
+	fieldsTmpl string
+
+	//go:embed static/source.html
+	sourceTmpl string
+
+	//go:embed static/index.html
+	indexTmpl string
+
 	//go:embed static/main.css
 	css []byte
 
@@ -45,55 +72,138 @@ func funcHeader(fd *ast.FuncSpec) string {
 	return "procedure"
 }
 
-func funcListing(fd *ast.FuncSpec) template.HTML {
-	res := funcHeader(fd)
+// Returns short labels for function's clauses
+// that are shown next to its name
+func funcBadges(fd *ast.FuncSpec) []string {
+	var res []string
 
-	if fd.Name != nil && fd.Name.Name != "" {
-		res += " " + fd.Name.Name
+	if fd.Deterministic {
+		res = append(res, "deterministic")
 	}
 
-	res += fieldListListing(fd.Params)
+	if fd.Pipelined {
+		res = append(res, "pipelined")
+	}
 
-	if fd.Ftype == ast.FtFunc {
-		res += " return " + fd.T.Name
+	if fd.ParallelEnable {
+		res = append(res, "parallel enabled")
 	}
 
-	return template.HTML(res)
-}
+	if fd.ResultCache {
+		res = append(res, "result cache")
+	}
 
-func fieldListListing(fl *ast.FieldList) string {
-	if fl == nil {
-		return ""
+	if fd.AggregateUsing != nil {
+		res = append(res, "aggregate")
 	}
 
-	var res string
+	if fd.SQLMacro != nil {
+		res = append(res, fd.SQLMacro.Name+" SQL macro")
+	}
 
-	if fl.List != nil {
-		res += "(\n"
-		for i := range fl.List {
-			last := i == len(fl.List)-1
-			var field = fl.List[i]
-			if field.Doc != nil {
-				var comments = strings.Split(field.Doc.Text(), "\n")
-				for _, c := range comments {
-					if (len(c) > 0) {
-						res += "    <span class=\"srcComment\">-- " + c + "</span>\n"
-					}
-				}
-			}
-			res += "    " + fl.List[i].String()
-			if !last {
-				res += ","
-			}
+	if len(fd.AccessibleBy) > 0 {
+		res = append(res, "restricted access")
+	}
 
-			res += "\n"
+	return append(res, pragmaBadges(fd.Pragmas)...)
+}
+
+// Returns labels for pragmas, like "autonomous transaction".
+// Deprecation is shown as a separate banner, so it's skipped.
+func pragmaBadges(list []*ast.Pragma) []string {
+	var res []string
+
+	for _, pr := range list {
+		switch pr.Name.Name {
+		case "deprecate":
+			continue
+		case "restrict_references":
+			// The first argument is the subprogram's name
+			// or "default", the rest are purity levels
+			if len(pr.Args) > 1 {
+				res = append(res, "restrict references: "+
+					strings.ToUpper(identList(pr.Args[1:])))
+				continue
+			}
 		}
-		res += ")"
+
+		res = append(res, strings.ReplaceAll(pr.Name.Name, "_", " "))
 	}
 
 	return res
 }
 
+// Returns package's clauses that are shown in the page header,
+// like "authid current_user". The rights model is always shown,
+// because it's what security reviews start with.
+func packageClauses(pck *ast.Package) []string {
+	var res []string
+
+	if pck.AuthID != nil {
+		res = append(res, "authid "+pck.AuthID.Name)
+	} else {
+		res = append(res, "authid definer (default)")
+	}
+
+	if len(pck.AccessibleBy) > 0 {
+		res = append(res, "accessible by ("+accessorList(pck.AccessibleBy)+")")
+	}
+
+	if pck.Edition != nil {
+		res = append(res, pck.Edition.Name)
+	}
+
+	if pck.Sharing != nil {
+		res = append(res, "sharing = "+pck.Sharing.Name)
+	}
+
+	return res
+}
+
+// Returns the package's name qualified
+// with its schema, if the schema is specified
+func packageFullName(pck *ast.Package) string {
+	if pck.Schema != nil {
+		return pck.Schema.Name + "." + pck.Name.Name
+	}
+
+	return pck.Name.Name
+}
+
+// Returns the banner for pragma deprecate with its message,
+// or an empty string if the list has no such pragma
+func deprecationBanner(list []*ast.Pragma) template.HTML {
+	pr := ast.FindPragma(list, "deprecate")
+	if pr == nil {
+		return ""
+	}
+
+	res := "<div class=\"deprecated\"><b>Deprecated.</b>"
+	if len(pr.Args) > 1 {
+		res += " " + template.HTMLEscapeString(strings.Trim(pr.Args[1].Name, "'"))
+	}
+
+	return template.HTML(res + "</div>")
+}
+
+func identList(list []*ast.Ident) string {
+	names := make([]string, len(list))
+	for i := range list {
+		names[i] = list[i].Name
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func accessorList(list []*ast.Accessor) string {
+	names := make([]string, len(list))
+	for i := range list {
+		names[i] = list[i].String()
+	}
+
+	return strings.Join(names, ", ")
+}
+
 func typeHeader(td *ast.TypeDecl) string {
 	switch td.Kind {
 	case ast.TkTable:
@@ -108,181 +218,350 @@ func typeHeader(td *ast.TypeDecl) string {
 	return ""
 }
 
-func typeListing(td *ast.TypeDecl) string {
-	res := "type " + td.Name.Name + " is " + typeHeader(td) +
-		fieldListListing(td.Params)
+// Returns method's modifiers and kind, like
+// "not instantiable map member "
+func methodModifiers(m *ast.Method) string {
+	var res string
 
-	if (td.Kind == ast.TkVarray || td.Kind == ast.TkTable) && td.T != nil {
-		res += " of " + td.T.Name
-	} else if td.Kind == ast.TkRefCursor && td.T != nil {
-		res += " return " + td.T.Name
+	if m.Overriding {
+		res += "overriding "
 	}
 
-	return res
+	if m.Final {
+		res += "final "
+	}
+
+	if !m.Instantiable {
+		res += "not instantiable "
+	}
+
+	if m.Map {
+		res += "map "
+	} else if m.Order {
+		res += "order "
+	}
+
+	return res + m.Kind.String() + " "
 }
 
-func cursorListing(cd *ast.CursorDecl) string {
-	return "cursor " + cd.Name.Name + fieldListListing(cd.Params) +
-		" is\n" + cd.SQL.Text
+func methodHeader(m *ast.Method) string {
+	return methodModifiers(m) + funcHeader(m.Spec)
 }
 
-// Returns index of the first non-space
-// character in a string. If string doesn't
-// have any spaces at the beginning, returns 0
-// If string doesn't have non-space characters,
-// returns -1
-func getFirstCharOffset(s string) int {
-	for i := range s {
-		if s[i] != ' ' {
-			return i
-		}
+// Overloaded subprograms share the name, so they are
+// documented under one heading
+type funcGroup struct {
+	Anchor string
+	Name   string
+	Items  []funcItem
+}
+
+// A subprogram with the anchor that is unique on its page
+type funcItem struct {
+	Anchor string
+	Spec   *ast.FuncSpec
+	Method *ast.Method // Set only for methods of object types
+}
+
+// Groups subprograms by name. Groups follow in the order of
+// the first subprogram with the name.
+func funcGroups(list []*ast.FuncSpec) []*funcGroup {
+	var groups []*funcGroup
+
+	for _, fs := range list {
+		groups = addToGroup(groups, "function_", funcItem{Spec: fs})
 	}
 
-	return -1
+	return groups
 }
 
-// Trims n spaces from the left side of a string
-func trimNSpaces(s string, n int) string {
-	var i int
-	for i = 0; i <= n && i < len(s); i++ {
-		if s[i] != ' ' {
+// Groups methods of an object type by name, like funcGroups
+func methodGroups(list []*ast.Method) []*funcGroup {
+	var groups []*funcGroup
+
+	for _, m := range list {
+		groups = addToGroup(groups, "method_", funcItem{Spec: m.Spec, Method: m})
+	}
+
+	return groups
+}
+
+// Adds the subprogram to the group with its name and sets its anchor.
+// The anchor is built from the parameter types, like
+// "function_get_user(number,varchar2)", so it doesn't change when
+// other overloads are added or reordered.
+func addToGroup(groups []*funcGroup, prefix string, item funcItem) []*funcGroup {
+	name := item.Spec.Name.Name
+
+	var group *funcGroup
+	for _, g := range groups {
+		if g.Name == name {
+			group = g
 			break
 		}
 	}
 
-	return s[i:]
+	if group == nil {
+		group = &funcGroup{Anchor: prefix + name, Name: name}
+		groups = append(groups, group)
+	}
+
+	var types []string
+	if item.Spec.Params != nil {
+		for _, f := range item.Spec.Params.List {
+			if f.T != nil {
+				types = append(types, anchorName(f.T.Name))
+			}
+		}
+	}
+
+	base := group.Anchor + "(" + strings.Join(types, ",") + ")"
+	item.Anchor = base
 
+	// Overloads may differ only in parameter names or modes,
+	// or be a function and a procedure
+	for n := 2; anchorUsed(group, item.Anchor); n++ {
+		item.Anchor = base + "_" + strconv.Itoa(n)
+	}
+
+	group.Items = append(group.Items, item)
+
+	return groups
 }
 
-func formatComment(cg *ast.CommentGroup) template.HTML {
-	if cg == nil {
-		return ""
+func anchorUsed(g *funcGroup, anchor string) bool {
+	for _, item := range g.Items {
+		if item.Anchor == anchor {
+			return true
+		}
 	}
 
-	lines := strings.Split(cg.Text(), "\n")
+	return false
+}
 
-	if len(lines) == 0 {
-		return ""
+// Replaces characters that need escaping in URLs, like spaces
+// and percent signs in "emp.id%type", with underscores
+func anchorName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.$", r) {
+			return r
+		}
+
+		return '_'
+	}, s)
+}
+
+// An entry of the "Quick jump" box
+type jumpItem struct {
+	Anchor string
+	Label  string
+}
+
+// Returns the label of a group in the "Quick jump" box,
+// like "get_user (3 overloads)"
+func groupLabel(g *funcGroup) string {
+	if len(g.Items) == 1 {
+		return g.Name
 	}
 
-	// Get the first non-space character's offset for the
-	// first line in the comment group.
-	offset := getFirstCharOffset(lines[0])
+	return g.Name + " (" + strconv.Itoa(len(g.Items)) + " overloads)"
+}
 
-	var res = make([]string, 0)
-	var buf = make([]string, 0)
-	fromPre := false
+func packageJumpItems(pck *ast.Package) []jumpItem {
+	var res []jumpItem
 
-	// Preformatted text offset related to ordinary comment text
-	var preOffs int
+	for _, vd := range pck.VarDecls {
+		res = append(res, jumpItem{"var_" + vd.Name.Name, varHeader(vd) + " " + vd.Name.Name})
+	}
 
-	for i := range lines {
-		currOff := getFirstCharOffset(lines[i])
+	for _, g := range funcGroups(pck.FuncSpecs) {
+		res = append(res, jumpItem{g.Anchor, funcHeader(g.Items[0].Spec) + " " + groupLabel(g)})
+	}
 
-		// Preformatted block starts.
-		// All previous text should be formatted as paragraph.
-		if currOff > offset {
-			// If we have not processing preformatted block
-			if !fromPre {
-				res = append(res, "<p>"+strings.Join(buf, "\n")+"</p>")
-				buf = make([]string, 0)
-				preOffs = currOff - offset
-				fromPre = true
-			}
-		} else if fromPre {
-			res = append(res, "<pre>"+strings.Join(buf, "\n")+"</pre>")
-			buf = make([]string, 0)
-			fromPre = false
-		}
+	for _, td := range pck.TypeDecls {
+		res = append(res, jumpItem{"type_" + td.Name.Name, "type " + td.Name.Name})
+	}
 
-		var tmp string
+	for _, sd := range pck.SubtypeDecls {
+		res = append(res, jumpItem{"subtype_" + sd.Name.Name, "subtype " + sd.Name.Name})
+	}
 
-		if fromPre {
-			tmp = trimNSpaces(lines[i], preOffs)
-		} else {
-			tmp = lines[i]
-		}
+	for _, cd := range pck.CursorDecls {
+		res = append(res, jumpItem{"cursor_" + cd.Name.Name, "cursor " + cd.Name.Name})
+	}
+
+	return res
+}
 
-		buf = append(buf, tmp)
+func typeJumpItems(t *ast.ObjectType) []jumpItem {
+	var res []jumpItem
 
+	for _, g := range methodGroups(t.Methods) {
+		res = append(res, jumpItem{g.Anchor, methodHeader(g.Items[0].Method) + " " + groupLabel(g)})
 	}
 
-	// Process unprocessed data
-	if len(buf) > 0 {
-		var str string
+	return res
+}
 
-		if fromPre {
-			str = "<pre>" + strings.Join(buf, "\n") + "</pre>"
-		} else {
-			str = "<p>" + strings.Join(buf, "\n") + "</p>"
+func funcJumpItems(list []*ast.FuncSpec) []jumpItem {
+	var res []jumpItem
+
+	for _, g := range funcGroups(list) {
+		res = append(res, jumpItem{g.Anchor, funcHeader(g.Items[0].Spec) + " " + groupLabel(g)})
+	}
+
+	return res
+}
+
+// Returns the first sentence of the comment's first paragraph,
+// which is shown as the summary in lists of declarations.
+// Line breaks are replaced with spaces.
+func summary(cg *ast.CommentGroup) string {
+	for _, b := range doc.Parse(cg.Text()).Blocks {
+		p, ok := b.(*doc.Paragraph)
+		if !ok {
+			continue
 		}
 
-		res = append(res, str)
+		text := strings.Join(strings.Fields(doc.TextString(p.Text)), " ")
+
+		// The sentence ends with a period that is followed by
+		// a space, so periods in names like pck.func are skipped
+		if i := strings.Index(text, ". "); i >= 0 {
+			return text[:i+1]
+		}
+
+		return text
 	}
 
-	return template.HTML(strings.Join(res, "\n"))
+	return ""
 }
 
+// The index page lists all packages, types and standalone subprograms
+const indexPage = "index.html"
+
+// The page with standalone functions and procedures. The hyphen
+// guarantees that the name doesn't clash with a package's page.
+const subprogramsPage = "standalone-subprograms.html"
+
 type reportData struct {
 	Package     *ast.Package
+	Type        *ast.ObjectType
 	PackageList []*ast.Package
+	TypeList    []*ast.ObjectType
+	FuncList    []*ast.FuncSpec
+	SourceFile  string        // Name of the file on the source page
+	Source      template.HTML // Highlighted source code
+}
+
+// Options of the generated documentation
+type Options struct {
+	// URL template of the external repository browser, like
+	// https://git.example.com/repo/blob/{rev}/{path}#L{line}.
+	// If it's set, declarations link to it instead of the
+	// generated source pages.
+	SourceURL string
+
+	// Revision that replaces {rev} in SourceURL
+	Rev string
+
+	// Paths of the files relative to the root directories, by
+	// file names. They replace {path} in SourceURL. Files that
+	// aren't in the map use their names as is.
+	Paths map[string]string
+
+	// Show @param, @return and other tags of subprograms'
+	// doc comments in their own sections
+	Tags bool
+
+	// If it isn't nil, problems in the documentation, like
+	// unresolved type references, are added to the list
+	Warnings *scanner.ErrorList
 }
 
-func Execute(dir string, f *ast.Files) error {
-	fm := template.FuncMap{
-		"varHeader":     varHeader,
-		"funcHeader":    funcHeader,
-		"funcListing":   funcListing,
-		"typeHeader":    typeHeader,
-		"typeListing":   typeListing,
-		"cursorListing": cursorListing,
-		"formatComment": formatComment,
+// Adds a warning to the list, if it's set
+func (o *Options) warn(pos token.Position, msg string) {
+	if o.Warnings != nil {
+		o.Warnings.Add(pos, msg)
 	}
+}
 
-	// Prepare directory
-	err := os.Mkdir(dir, 0750)
-	if err != nil && !os.IsExist(err) {
-		return err
+// Returns the link to the line in the external repository browser
+func (o *Options) sourceURL(fname string, line int) string {
+	path, ok := o.Paths[fname]
+	if !ok {
+		path = fname
 	}
 
-	// Create css and js files
-	err = os.WriteFile(filepath.Join(dir, "main.css"), css, 0666)
-	if err != nil {
-		return err
+	r := strings.NewReplacer(
+		"{rev}", escapePath(o.Rev),
+		"{path}", escapePath(filepath.ToSlash(path)),
+		"{line}", strconv.Itoa(line),
+	)
+
+	return r.Replace(o.SourceURL)
+}
+
+// Escapes each part of a slash-separated path, but keeps
+// the slashes, because revisions like "release/1.0" and
+// file paths are put into the URL as they are
+func escapePath(s string) string {
+	parts := strings.Split(s, "/")
+	for i := range parts {
+		parts[i] = url.PathEscape(parts[i])
 	}
 
-	err = os.WriteFile(filepath.Join(dir, "pldoc.js"), js, 0666)
-	if err != nil {
-		return err
+	return strings.Join(parts, "/")
+}
+
+// Builds links from declarations to their source code
+type sourceLinker struct {
+	fset  *token.FileSet
+	opts  Options
+	pages map[string]string // Source pages by file names
+}
+
+// Returns the link to the line of the node in the external
+// repository browser or on the source page. Returns an empty
+// string if the file has no source page.
+func (l *sourceLinker) link(n ast.Node) string {
+	if l.fset == nil {
+		return ""
 	}
 
-	t, err := template.New("Documentation").Funcs(fm).Parse(tmpl)
-	if err != nil {
-		return err
+	pos := l.fset.Position(n.Start())
+	if !pos.IsValid() {
+		return ""
 	}
 
-	for i := range f.Files {
-		for fn := range f.Files[i].Packages {
-			// Create file for each pl/sql package
-			fdoc, err := os.Create(filepath.Join(dir, f.Files[i].Packages[fn].Name.Name+".html"))
-			if err != nil {
-				return err
-			}
+	if l.opts.SourceURL != "" {
+		return l.opts.sourceURL(pos.Filename, pos.Line)
+	}
 
-			pckList := f.GetPackages()
-			err = t.Execute(fdoc,
-				reportData{
-					Package:     f.Files[i].Packages[fn],
-					PackageList: pckList,
-				})
+	page, ok := l.pages[pos.Filename]
+	if !ok {
+		return ""
+	}
 
-			if err != nil {
-				return err
-			}
+	return page + "#L" + strconv.Itoa(pos.Line)
+}
 
-			if err = fdoc.Close(); err != nil {
-				panic(err)
-			}
+// Generates the HTML documentation in the directory
+func Execute(dir string, f *ast.Files, opts Options) error {
+	site, err := NewSite(f, opts)
+	if err != nil {
+		return err
+	}
+
+	// Prepare directory
+	err = os.Mkdir(dir, 0750)
+	if err != nil && !os.IsExist(err) {
+		return err
+	}
+
+	for _, name := range site.Pages() {
+		if err = site.WritePage(dir, name); err != nil {
+			return err
 		}
 	}
 
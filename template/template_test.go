@@ -0,0 +1,964 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cyevgeniy/pldoc/ast"
+	"github.com/cyevgeniy/pldoc/parser"
+	"github.com/cyevgeniy/pldoc/token"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Parses the source that is written into the dir
+// directory, so it has a source page
+func parseFiles(t *testing.T, dir string, src string) *ast.Files {
+	fname := filepath.Join(dir, "src.pks")
+	if err := os.WriteFile(fname, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	files := &ast.Files{FileSet: token.NewFileSet()}
+	f, err := parser.ParseFile(files.FileSet, fname, []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files.Add(f)
+
+	return files
+}
+
+var escapeSrc = `
+create or replace package pck is
+  procedure p(
+    -- <b>bold</b> & co
+    p_name varchar2 default '<none>'
+  );
+end pck;
+`
+
+func TestListingEscapes(t *testing.T) {
+	files := parseFiles(t, t.TempDir(), escapeSrc)
+	fd := files.Files[0].Packages[0].FuncSpecs[0]
+
+	got := string(textLister.funcListing(fd))
+	want := "procedure p(\n" +
+		"    <span class=\"srcComment\">-- &lt;b&gt;bold&lt;/b&gt; &amp; co</span>\n" +
+		"    p_name varchar2 default &#39;&lt;none&gt;&#39;\n" +
+		")"
+
+	if got != want {
+		t.Errorf("HTML listing:\ngot  %q\nwant %q", got, want)
+	}
+
+	text := textLister.funcSignature(fd).String()
+	if !strings.Contains(text, "-- <b>bold</b> & co") || !strings.Contains(text, "default '<none>'") {
+		t.Errorf("text listing shouldn't be escaped, got %q", text)
+	}
+}
+
+// Every place where the sources get into the pages
+// has a payload that tries to inject markup
+var hostileSrc = `
+-- <script>alert('package')</script>
+create or replace package hostile is
+  -- <img src=x onerror=alert('var')>
+  c_val constant varchar2(30) := '<script>alert(1)</script>';
+
+  -- Paragraph <script>alert('func')</script>
+  --
+  --     <script>alert('pre')</script>
+  --
+  -- @return <script>alert('return')</script>
+  function f(
+    -- <script>alert('param')</script>
+    p_val varchar2 default '<img src=x onerror=alert(1)>'
+  ) return varchar2;
+  pragma deprecate(f, '<script>alert("deprecated")</script>');
+
+  -- <script>alert('type')</script>
+  type t_rec is record (
+    -- <script>alert('field')</script>
+    id number
+  );
+
+  -- <script>alert('cursor')</script>
+  cursor c is select '<script>alert(1)</script>' from dual;
+end hostile;
+`
+
+func TestHostileComments(t *testing.T) {
+	srcDir := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), "docs")
+
+	files := parseFiles(t, srcDir, hostileSrc)
+	if err := Execute(outDir, files, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	pages, err := filepath.Glob(filepath.Join(outDir, "*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pages) == 0 {
+		t.Fatal("no pages were written")
+	}
+
+	for _, page := range pages {
+		data, err := os.ReadFile(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		html := string(data)
+		// Attributes like onerror are harmless
+		// as long as the tags are escaped
+		for _, payload := range []string{"<script>alert", "<img"} {
+			if strings.Contains(html, payload) {
+				t.Errorf("%s: unescaped %q", filepath.Base(page), payload)
+			}
+		}
+	}
+}
+
+// Removes fenced code blocks and code spans, which
+// show their text as it's written
+var mdCodeRe = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`")
+
+func TestHostileMarkdown(t *testing.T) {
+	files := parseFiles(t, t.TempDir(), hostileSrc)
+
+	for _, tags := range []bool{false, true} {
+		outDir := filepath.Join(t.TempDir(), "docs")
+		if err := ExecuteMarkdown(outDir, files, Options{Tags: tags}); err != nil {
+			t.Fatal(err)
+		}
+
+		pages, err := filepath.Glob(filepath.Join(outDir, "*.md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, page := range pages {
+			data, err := os.ReadFile(page)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			md := mdCodeRe.ReplaceAllString(string(data), "")
+			for _, payload := range []string{"<script", "<img"} {
+				if strings.Contains(md, payload) {
+					t.Errorf("tags=%v: %s: unescaped %q", tags, filepath.Base(page), payload)
+				}
+			}
+		}
+	}
+}
+
+func TestMarkdownEscape(t *testing.T) {
+	for _, test := range []struct {
+		text, want string
+	}{
+		{"a <b> & c", "a &lt;b&gt; &amp; c"},
+		{"*not* [bold] `x` a\\b", "\\*not\\* \\[bold\\] \\`x\\` a\\\\b"},
+		{"p_user_id, _private_", "p_user_id, \\_private\\_"},
+		{"# one\n- two\n  3. three\nfour - five", "\\# one\n\\- two\n  3\\. three\nfour - five"},
+	} {
+		if got := mdEscape(test.text, true); got != test.want {
+			t.Errorf("mdEscape(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+var tagsSrc = `
+create or replace package order_api is
+  e_no_order exception;
+
+  -- Creates an order.
+  -- @param p_customer the customer
+  -- @param p_bogus no such parameter
+  -- @return id of the new order
+  -- @throws e_no_order when <customer> is missing
+  -- @see cancel_order
+  -- @see nowhere
+  function create_order(p_customer number) return number;
+
+  procedure cancel_order(p_id number);
+end order_api;
+`
+
+func TestFuncDocTags(t *testing.T) {
+	files := parseFiles(t, t.TempDir(), tagsSrc)
+	fd := files.Files[0].Packages[0].FuncSpecs[0]
+
+	refs := newCrossRefs(files)
+	docs := &funcDocs{refs: refs, ls: lister{refs: refs}, tags: true}
+
+	html := string(docs.funcDoc(fd))
+	for _, want := range []string{
+		"Creates an order.",
+		"<td><code>p_customer</code></td><td><code>number</code></td><td><p>the customer</p></td>",
+		"<dt>Returns</dt><dd>id of the new order</dd>",
+		`<a href="order_api.html#var_e_no_order"><code>e_no_order</code></a> when &lt;customer&gt; is missing`,
+		`<a href="order_api.html#function_cancel_order"><code>cancel_order</code></a>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("funcDoc doesn't contain %q:\n%s", want, html)
+		}
+	}
+
+	if strings.Contains(html, "@param") {
+		t.Errorf("tags are left in the text:\n%s", html)
+	}
+
+	var warnings []string
+	docs.check(files, func(pos token.Position, msg string) {
+		warnings = append(warnings, msg)
+	})
+
+	want := []string{
+		"@param p_bogus: function create_order has no such parameter",
+		"unresolved @see reference nowhere",
+	}
+	if strings.Join(warnings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("warnings:\ngot  %q\nwant %q", warnings, want)
+	}
+}
+
+var docLinksSrc = `
+-- Orders. See [create_order] and [helpers.p].
+create or replace package order_api is
+  -- Creates an order, [cancel_order] cancels it.
+  -- Not links: a[i], [text](url), [no_such.name].
+  function create_order return number;
+
+  procedure cancel_order;
+end order_api;
+
+create or replace package helpers is
+  -- Calls [order_api.create_order], but not [cancel_order]
+  procedure p;
+end helpers;
+`
+
+func TestDocLinks(t *testing.T) {
+	files := parseFiles(t, t.TempDir(), docLinksSrc)
+	pcks := files.Files[0].Packages
+	refs := newCrossRefs(files)
+
+	tests := []struct {
+		cg   *ast.CommentGroup
+		want string
+	}{
+		{
+			pcks[0].Doc,
+			`<p>Orders. See <a href="order_api.html#function_create_order">create_order</a> and <a href="helpers.html#function_p">helpers.p</a>.</p>`,
+		},
+		{
+			pcks[0].FuncSpecs[0].Doc,
+			`<p>Creates an order, <a href="order_api.html#function_cancel_order">cancel_order</a> cancels it.` + "\n" +
+				`Not links: a[i], [text](url), [no_such.name].</p>`,
+		},
+		{
+			pcks[1].FuncSpecs[0].Doc,
+			`<p>Calls <a href="order_api.html#function_create_order">order_api.create_order</a>, but not [cancel_order]</p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := string(refs.formatComment(tt.cg)); got != tt.want {
+			t.Errorf("formatComment:\ngot  %q\nwant %q", got, tt.want)
+		}
+	}
+
+	var warnings []string
+	refs.checkDocLinks(files, func(pos token.Position, msg string) {
+		warnings = append(warnings, msg)
+	})
+
+	want := []string{
+		"unresolved doc link [no_such.name]",
+		"unresolved doc link [cancel_order]",
+	}
+	if strings.Join(warnings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("warnings:\ngot  %q\nwant %q", warnings, want)
+	}
+}
+
+// Every section's doc has a paragraph and a preformatted block,
+// which were lost when docs were printed as plain text
+var sectionsSrc = `
+-- Package doc.
+--     package code
+create or replace package sections is
+  -- Variable doc.
+  --     variable code
+  c_val constant number := 1;
+
+  -- Function doc.
+  --     function code
+  function f return number;
+
+  -- Record doc.
+  --     record code
+  type t_rec is record (
+    -- Field doc.
+    --     field code
+    id number,
+    name varchar2(30)
+  );
+
+  -- Subtype doc.
+  --     subtype code
+  subtype t_id is number;
+
+  -- Cursor doc.
+  --     cursor code
+  cursor c is select 1 from dual;
+end sections;
+
+-- Object type doc.
+--     object type code
+create or replace type t_obj as object (
+  -- Attribute doc.
+  --     attribute code
+  id number,
+  -- Method doc.
+  --     method code
+  member function get_id return number
+);
+
+-- Standalone doc.
+--     standalone code
+create function get_version return varchar2 is
+begin
+  return '1.0';
+end;
+`
+
+func TestDocSections(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "docs")
+
+	files := parseFiles(t, t.TempDir(), sectionsSrc)
+	if err := Execute(outDir, files, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		page    string
+		section string
+	}{
+		{"sections.html", "Package"},
+		{"sections.html", "Variable"},
+		{"sections.html", "Function"},
+		{"sections.html", "Record"},
+		{"sections.html", "Field"},
+		{"sections.html", "Subtype"},
+		{"sections.html", "Cursor"},
+		{"t_obj.html", "Object type"},
+		{"t_obj.html", "Attribute"},
+		{"t_obj.html", "Method"},
+		{subprogramsPage, "Standalone"},
+	}
+
+	for _, tt := range tests {
+		data, err := os.ReadFile(filepath.Join(outDir, tt.page))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		html := string(data)
+		want := "<p>" + tt.section + " doc.</p>\n<pre>" + strings.ToLower(tt.section) + " code</pre>"
+		if !strings.Contains(html, want) {
+			t.Errorf("%s: %s doc isn't formatted, want %q", tt.page, tt.section, want)
+		}
+	}
+
+	// Field docs are shown in the table rather than in the listing
+	data, err := os.ReadFile(filepath.Join(outDir, "sections.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(data), `<span class="srcComment">-- Field doc.`) {
+		t.Error("field docs are repeated in the record's listing")
+	}
+}
+
+var lookupSrc = `
+-- Orders API.
+create or replace package sales.order_api is
+  -- Default status.
+  c_status constant varchar2(10) := 'new';
+
+  -- Creates an order.
+  function create_order(p_customer number) return number;
+
+  -- Creates an order with the amount.
+  function create_order(p_customer number, p_amount number) return number;
+end order_api;
+
+-- Returns the version
+create function get_version return varchar2 is
+begin
+  return '1.0';
+end;
+`
+
+func TestWriteDoc(t *testing.T) {
+	files := parseFiles(t, t.TempDir(), lookupSrc)
+
+	lookup := func(name string, all bool) string {
+		var b strings.Builder
+		if err := WriteDoc(&b, files, name, all); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return b.String()
+	}
+
+	overloads := "function create_order(\n    p_customer number\n) return number\n" +
+		"    Creates an order.\n\n" +
+		"function create_order(\n    p_customer number,\n    p_amount number\n) return number\n" +
+		"    Creates an order with the amount.\n"
+
+	for _, name := range []string{"order_api.create_order", "ORDER_API.Create_Order", "sales.order_api.create_order", "create_order"} {
+		if got := lookup(name, false); got != overloads {
+			t.Errorf("%s:\ngot  %q\nwant %q", name, got, overloads)
+		}
+	}
+
+	pck := "package sales.order_api authid definer (default)\n" +
+		"    Orders API.\n\n" +
+		"    c_status constant varchar2(10) := 'new'\n" +
+		"    function create_order(p_customer number) return number\n" +
+		"    function create_order(p_customer number, p_amount number) return number\n"
+	if got := lookup("order_api", false); got != pck {
+		t.Errorf("package:\ngot  %q\nwant %q", got, pck)
+	}
+
+	if got := lookup("order_api", true); !strings.Contains(got, "    Default status.\n") || !strings.Contains(got, overloads) {
+		t.Errorf("package with -all doesn't contain the docs of declarations:\n%s", got)
+	}
+
+	if got := lookup("Get_Version", false); !strings.Contains(got, "    Returns the version\n") {
+		t.Errorf("standalone function:\n%s", got)
+	}
+
+	index := "package sales.order_api — Orders API.\nfunction get_version — Returns the version\n"
+	if got := lookup("", false); got != index {
+		t.Errorf("index:\ngot  %q\nwant %q", got, index)
+	}
+
+	if err := WriteDoc(io.Discard, files, "order_api.no_such", false); err == nil {
+		t.Error("no error for unknown name")
+	}
+}
+
+func TestSiteAffected(t *testing.T) {
+	dir := t.TempDir()
+	fset := token.NewFileSet()
+
+	parse := func(name string, src string) *ast.File {
+		fname := filepath.Join(dir, name)
+		if err := os.WriteFile(fname, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := parser.ParseFile(fset, fname, []byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	site := func(files ...*ast.File) *Site {
+		s, err := NewSite(&ast.Files{FileSet: fset, Files: files}, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	a := parse("a.pks", "create package a is\n  -- Uses [b.missing]\n  procedure p;\nend a;\n")
+	b := parse("b.pks", "create package b is\n  procedure p;\nend b;\n")
+	old := site(a, b)
+
+	if got := old.Affected(nil, nil); len(got) != len(old.Pages()) {
+		t.Errorf("first generation: got %v, want all pages", got)
+	}
+
+	// A new doc changes only the file's pages and the index
+	b = parse("b.pks", "create package b is\n  -- Doc\n  procedure p;\n  procedure q;\nend b;\n")
+	changed := []string{filepath.Join(dir, "b.pks")}
+	got := site(a, b).Affected(old, changed)
+	want := []string{indexPage, searchIndexFile, sourcePageName(changed[0]), "b.html"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("doc change:\ngot  %v\nwant %v", got, want)
+	}
+
+	// A declaration that a link is waiting for changes other pages
+	b = parse("b.pks", "create package b is\n  procedure p;\n  procedure missing;\nend b;\n")
+	if got := site(a, b).Affected(old, changed); len(got) != len(old.Pages()) {
+		t.Errorf("new link target: got %v, want all pages", got)
+	}
+
+	// So does a new page
+	c := parse("c.pks", "create package c is\n  procedure p;\nend c;\n")
+	if got := site(a, b, c).Affected(old, changed); len(got) != len(old.Pages())+2 {
+		t.Errorf("new package: got %v, want all pages", got)
+	}
+}
+
+func TestSourcePages(t *testing.T) {
+	fset := token.NewFileSet()
+	files := &ast.Files{FileSet: fset}
+	paths := make(map[string]string)
+
+	src := "create package a is\n  /* Two\n     lines */\n  procedure p;\nend a;\n"
+
+	// Both directories have the same relative path
+	for _, dir := range []string{t.TempDir(), t.TempDir()} {
+		fname := filepath.Join(dir, "a.pks")
+		if err := os.WriteFile(fname, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := parser.ParseFile(fset, fname, []byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files.Add(f)
+		paths[fname] = "a.pks"
+	}
+
+	site, err := NewSite(files, Options{Paths: paths})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := site.Render(&b, "source-a.pks.html"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<span class="srcLine" id="L2"><a href="#L2" class="lineNum">2</a>  <span class="srcComment">/* Two</span></span>` + "\n" +
+		`<span class="srcLine" id="L3"><a href="#L3" class="lineNum">3</a><span class="srcComment">     lines */</span></span>`
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("source page doesn't contain\n%s\ngot\n%s", want, b.String())
+	}
+
+	if strings.Contains(b.String(), `id="L6"`) {
+		t.Error("the final newline starts a new line")
+	}
+
+	if err := site.Render(io.Discard, "source-a.pks-2.html"); err != nil {
+		t.Errorf("no page for the second file: %v", err)
+	}
+}
+
+func TestSourcePagesLazy(t *testing.T) {
+	files := parseFiles(t, t.TempDir(), "create package a is\n  procedure p;\nend a;\n")
+	fname := files.Files[0].Name
+
+	site, err := NewSite(files, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The source is read when the page is rendered, and
+	// its line table is out of date once the file changes
+	if err := os.WriteFile(fname, []byte("create package a is\nend a;\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := site.Render(io.Discard, sourcePageName(fname)); err == nil {
+		t.Error("no error for the changed source")
+	}
+
+	// A change that keeps the size of the file
+	if err := os.WriteFile(fname, []byte("create package b is\n  procedure q;\nend b;\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := site.Render(io.Discard, sourcePageName(fname)); err == nil {
+		t.Error("no error for the source of the same size")
+	}
+
+	if err := site.Render(io.Discard, "a.html"); err != nil {
+		t.Errorf("documentation page: %v", err)
+	}
+}
+
+var overloadsSrc = `
+create or replace package overloads is
+  procedure log(p_msg varchar2);
+  procedure log(p_text varchar2);
+  procedure log(p_msg in out varchar2);
+  function log(p_msg varchar2) return number;
+  procedure log(p_level number, p_msg varchar2);
+
+  function get(p_id emp.id%type) return number;
+  procedure get;
+  function get return number;
+end overloads;
+`
+
+func TestOverloadAnchors(t *testing.T) {
+	files := parseFiles(t, t.TempDir(), overloadsSrc)
+	groups := funcGroups(files.Files[0].Packages[0].FuncSpecs)
+
+	want := []struct {
+		label   string
+		anchors []string
+	}{
+		{
+			"log (5 overloads)",
+			[]string{
+				"function_log(varchar2)",
+				"function_log(varchar2)_2",
+				"function_log(varchar2)_3",
+				"function_log(varchar2)_4",
+				"function_log(number,varchar2)",
+			},
+		},
+		{
+			"get (3 overloads)",
+			[]string{
+				"function_get(emp.id_type)",
+				"function_get()",
+				"function_get()_2",
+			},
+		},
+	}
+
+	if len(groups) != len(want) {
+		t.Fatalf("expected %d groups; got %d", len(want), len(groups))
+	}
+
+	for i, g := range groups {
+		if got := groupLabel(g); got != want[i].label {
+			t.Errorf("groupLabel: got %q, want %q", got, want[i].label)
+		}
+
+		var anchors []string
+		for _, item := range g.Items {
+			anchors = append(anchors, item.Anchor)
+		}
+		if strings.Join(anchors, " ") != strings.Join(want[i].anchors, " ") {
+			t.Errorf("anchors of %s:\ngot  %q\nwant %q", g.Name, anchors, want[i].anchors)
+		}
+	}
+
+	single := &funcGroup{Name: "p", Items: []funcItem{{}}}
+	if got := groupLabel(single); got != "p" {
+		t.Errorf("groupLabel: got %q, want %q", got, "p")
+	}
+}
+
+func TestAnchorName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"number", "number"},
+		{"emp.id%type", "emp.id_type"},
+		{"timestamp with time zone", "timestamp_with_time_zone"},
+		{"v$session%rowtype", "v$session_rowtype"},
+		{"varchar2(100)", "varchar2_100_"},
+	}
+
+	for _, tt := range tests {
+		if got := anchorName(tt.in); got != tt.want {
+			t.Errorf("anchorName(%q): got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSourceURL(t *testing.T) {
+	root := filepath.Join("work", "db")
+	fname := filepath.Join(root, "hr", "emp api.pks")
+
+	tests := []struct {
+		opts  Options
+		fname string
+		want  string
+	}{
+		{
+			Options{SourceURL: "https://git.example.com/repo/blob/{rev}/{path}#L{line}", Rev: "main"},
+			"hr/emp_api.pks",
+			"https://git.example.com/repo/blob/main/hr/emp_api.pks#L12",
+		},
+		{
+			// The path is relative to the walked root
+			Options{
+				SourceURL: "https://git.example.com/repo/blob/{rev}/{path}#L{line}",
+				Rev:       "main",
+				Paths:     map[string]string{fname: filepath.Join("hr", "emp api.pks")},
+			},
+			fname,
+			"https://git.example.com/repo/blob/main/hr/emp%20api.pks#L12",
+		},
+		{
+			Options{SourceURL: "https://git.example.com/repo/blob/{rev}/{path}#L{line}", Rev: "release/1.0"},
+			"emp_api.pks",
+			"https://git.example.com/repo/blob/release/1.0/emp_api.pks#L12",
+		},
+		{
+			Options{SourceURL: "https://git.example.com/repo/src/{path}?at={rev}&line={line}", Rev: "v1.0#rc?"},
+			"a%b.pks",
+			"https://git.example.com/repo/src/a%25b.pks?at=v1.0%23rc%3F&line=12",
+		},
+		{
+			// Placeholders that aren't in the template are fine
+			Options{SourceURL: "https://git.example.com/{path}", Rev: "main"},
+			"emp_api.pks",
+			"https://git.example.com/emp_api.pks",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.opts.sourceURL(tt.fname, 12); got != tt.want {
+			t.Errorf("sourceURL(%q):\ngot  %q\nwant %q", tt.fname, got, tt.want)
+		}
+	}
+}
+
+func TestEscapePath(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"release/1.0", "release/1.0"},
+		{"feature/a b", "feature/a%20b"},
+		{"hr/emp%api.pks", "hr/emp%25api.pks"},
+		{"v1#2?", "v1%232%3F"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := escapePath(tt.in); got != tt.want {
+			t.Errorf("escapePath(%q): got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+var indexSrc = `
+-- Orders API. Creates and cancels orders.
+create or replace package order_api is
+  c_status constant varchar2(10) := 'new';
+
+  -- Creates an order.
+  function create_order(p_customer number) return number;
+  function create_order(p_customer number, p_note varchar2) return number;
+
+  type t_rec is record (id number);
+  subtype t_id is number;
+  cursor c_orders is select 1 from dual;
+end order_api;
+
+-- Customers of hr.emp_api and others.
+-- Second sentence.
+create or replace package customers is
+  procedure p;
+end customers;
+
+create or replace package undocumented is
+  procedure p;
+end undocumented;
+
+-- Object type.
+create or replace type t_obj as object (
+  id number,
+  member function get_id return number
+);
+
+-- Standalone function.
+create function get_version return varchar2 is
+begin
+  return '1.0';
+end;
+`
+
+func TestIndexPages(t *testing.T) {
+	files := parseFiles(t, t.TempDir(), indexSrc)
+
+	site, err := NewSite(files, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	render := func(name string) string {
+		var b strings.Builder
+		if err := site.Render(&b, name); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return b.String()
+	}
+
+	// Every package is listed with the first sentence of its doc
+	index := render(indexPage)
+	rowRe := regexp.MustCompile(`<td><a href="([^"]*)">([^<]*)</a></td>\s*<td>([^<]*)</td>`)
+	var rows []string
+	for _, m := range rowRe.FindAllStringSubmatch(index, -1) {
+		rows = append(rows, m[1]+" "+m[2]+": "+m[3])
+	}
+
+	want := []string{
+		"order_api.html order_api: Orders API.",
+		"customers.html customers: Customers of hr.emp_api and others.",
+		"undocumented.html undocumented: ",
+		"t_obj.html t_obj: Object type.",
+		"standalone-subprograms.html#function_get_version get_version: Standalone function.",
+	}
+	if strings.Join(rows, "\n") != strings.Join(want, "\n") {
+		t.Errorf("index rows:\ngot  %q\nwant %q", rows, want)
+	}
+
+	// Every entry of the search index links to a page that
+	// exists, and to an element on it
+	script := render(searchIndexFile)
+	if !strings.HasPrefix(script, "var searchIndex = ") || !strings.HasSuffix(script, ";\n") {
+		t.Fatalf("search index isn't a script: %q", script)
+	}
+
+	var entries []searchEntry
+	data := strings.TrimSuffix(strings.TrimPrefix(script, "var searchIndex = "), ";\n")
+	if err := json.Unmarshal([]byte(data), &entries); err != nil {
+		t.Fatal(err)
+	}
+
+	pages := make(map[string]string)
+	for _, name := range site.Pages() {
+		pages[name] = ""
+	}
+
+	labels := make(map[string]bool)
+	for _, e := range entries {
+		labels[e.Label] = true
+
+		parts := strings.SplitN(e.URL, "#", 2)
+		if _, ok := pages[parts[0]]; !ok {
+			t.Errorf("%s: page %s doesn't exist", e.Label, parts[0])
+			continue
+		}
+
+		if len(parts) == 1 {
+			continue
+		}
+
+		if pages[parts[0]] == "" {
+			pages[parts[0]] = render(parts[0])
+		}
+		if !strings.Contains(pages[parts[0]], `id="`+parts[1]+`"`) {
+			t.Errorf("%s: %s has no element with id %q", e.Label, parts[0], parts[1])
+		}
+	}
+
+	for _, label := range []string{
+		"package order_api",
+		"package customers",
+		"package undocumented",
+		"type t_obj",
+		"standalone subprograms",
+	} {
+		if !labels[label] {
+			t.Errorf("search index has no %q entry", label)
+		}
+	}
+}
+
+var typeRefsSrc = `
+create or replace package hr.orders is
+  type t_rec is record (id number);
+  type t_tab is table of t_rec;
+
+  function get(p_id emp.id%type, p_row emp%rowtype) return t_rec;
+end orders;
+
+create or replace package customers is
+  type t_rec is record (name varchar2(100));
+
+  function get return t_rec;
+  function f(p_order orders.t_rec, p_orders hr.orders.t_tab) return t_obj;
+  procedure p(p_x t_missing, p_y other_pck.t_x, p_z varchar2(10), p_d date);
+end customers;
+
+create or replace type t_obj as object (
+  id number,
+  member function get_rec return orders.t_rec
+);
+`
+
+func TestTypeRefs(t *testing.T) {
+	files := parseFiles(t, t.TempDir(), typeRefsSrc)
+	pcks := files.Files[0].Packages
+	refs := newCrossRefs(files)
+	ls := lister{refs: refs}
+
+	linkRe := regexp.MustCompile(`<a href="([^"]*)" class="typeRef">([^<]*)</a>`)
+	links := func(fs *ast.FuncSpec) []string {
+		var res []string
+		for _, m := range linkRe.FindAllStringSubmatch(string(ls.funcListing(fs)), -1) {
+			res = append(res, m[2]+" "+m[1])
+		}
+		return res
+	}
+
+	tests := []struct {
+		fs   *ast.FuncSpec
+		want []string
+	}{
+		{
+			// Unqualified names resolve in the enclosing package;
+			// %type, %rowtype and builtin types are skipped
+			pcks[0].FuncSpecs[0],
+			[]string{"t_rec orders.html#type_t_rec"},
+		},
+		{
+			pcks[1].FuncSpecs[0],
+			[]string{"t_rec customers.html#type_t_rec"},
+		},
+		{
+			pcks[1].FuncSpecs[1],
+			[]string{
+				"orders.t_rec orders.html#type_t_rec",
+				"hr.orders.t_tab orders.html#type_t_tab",
+				"t_obj t_obj.html",
+			},
+		},
+		{
+			pcks[1].FuncSpecs[2],
+			nil,
+		},
+		{
+			files.Files[0].Types[0].Methods[0].Spec,
+			[]string{"orders.t_rec orders.html#type_t_rec"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := links(tt.fs); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("links of %s:\ngot  %q\nwant %q", tt.fs.Name.Name, got, tt.want)
+		}
+	}
+
+	var warnings []string
+	refs.checkTypes(files, func(pos token.Position, msg string) {
+		warnings = append(warnings, fmt.Sprintf("%d: %s", pos.Line, msg))
+	})
+
+	want := []string{
+		"14: unresolved type reference t_missing",
+		"14: unresolved type reference other_pck.t_x",
+	}
+	if strings.Join(warnings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("warnings:\ngot  %q\nwant %q", warnings, want)
+	}
+}
@@ -0,0 +1,383 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"fmt"
+	"github.com/cyevgeniy/pldoc/ast"
+	"github.com/cyevgeniy/pldoc/doc"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Comments are indented under declarations like in go doc output
+const textIndent = "    "
+
+// Writes docs as plain text for the terminal
+type textWriter struct {
+	b strings.Builder
+}
+
+func (w *textWriter) line(s ...string) {
+	for i := range s {
+		w.b.WriteString(s[i])
+	}
+	w.b.WriteString("\n")
+}
+
+// Writes the text's lines with the prefix
+func (w *textWriter) lines(prefix string, text string) {
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			w.line()
+		} else {
+			w.line(prefix, line)
+		}
+	}
+}
+
+// Writes the comment indented. Code blocks are indented once
+// more, list items are written with their markers.
+func (w *textWriter) comment(cg *ast.CommentGroup) {
+	if cg == nil {
+		return
+	}
+
+	for i, b := range doc.Parse(cg.Text()).Blocks {
+		if i > 0 {
+			w.line()
+		}
+
+		switch b := b.(type) {
+		case *doc.Paragraph:
+			w.lines(textIndent, doc.TextString(b.Text))
+		case *doc.Heading:
+			w.line(textIndent, "# ", doc.TextString(b.Text))
+		case *doc.Code:
+			w.lines(textIndent+textIndent, b.Text)
+		case *doc.List:
+			for i, item := range b.Items {
+				marker := "- "
+				if b.Ordered {
+					marker = item.Number + ". "
+					if item.Number == "" {
+						marker = strconv.Itoa(i+1) + ". "
+					}
+				}
+
+				text := strings.ReplaceAll(doc.TextString(item.Text), "\n", "\n"+strings.Repeat(" ", len(marker)))
+				w.lines(textIndent+"  ", marker+text)
+			}
+		}
+	}
+}
+
+func (w *textWriter) deprecation(list []*ast.Pragma) {
+	pr := ast.FindPragma(list, "deprecate")
+	if pr == nil {
+		return
+	}
+
+	if len(pr.Args) > 1 {
+		w.line(textIndent, "Deprecated: ", strings.Trim(pr.Args[1].Name, "'"))
+	} else {
+		w.line(textIndent, "Deprecated.")
+	}
+}
+
+// Writes the docs of record fields or attributes, one per line
+func (w *textWriter) fields(list []*ast.Field) {
+	for _, f := range list {
+		if f.Doc != nil {
+			w.line(textIndent, f.Name.Name, ": ", oneLine(f.Doc.Text()))
+		}
+	}
+}
+
+// Writes the declaration's signature followed by its doc
+func (w *textWriter) decl(signature string, cg *ast.CommentGroup) {
+	w.line(signature)
+	w.comment(cg)
+	w.line()
+}
+
+func (w *textWriter) funcGroup(g *funcGroup) {
+	for _, item := range g.Items {
+		if item.Method != nil {
+			w.line(textLister.methodSignature(item.Method).String())
+		} else {
+			w.line(textLister.funcSignature(item.Spec).String())
+		}
+		w.deprecation(item.Spec.Pragmas)
+		w.comment(item.Spec.Doc)
+		w.line()
+	}
+}
+
+func (w *textWriter) typeDecl(td *ast.TypeDecl) {
+	w.line(textLister.typeSignature(td).String())
+	w.comment(td.Doc)
+	if td.Params != nil {
+		w.fields(td.Params.List)
+	}
+	w.line()
+}
+
+// Returns the signature on one line, without
+// the docs of parameters and the cursor's query
+func compact(signature string) string {
+	if i := strings.Index(signature, " is\n"); i >= 0 {
+		signature = signature[:i]
+	}
+
+	var parts []string
+	for _, line := range strings.Split(signature, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			parts = append(parts, line)
+		}
+	}
+
+	res := strings.Join(parts, " ")
+	res = strings.ReplaceAll(res, "( ", "(")
+	res = strings.ReplaceAll(res, " )", ")")
+
+	return res
+}
+
+// Writes the package's doc. With all, every declaration is written
+// with its doc, otherwise only their signatures on one line.
+func (w *textWriter) pck(pck *ast.Package, all bool) {
+	w.line("package ", packageFullName(pck), " ", strings.Join(packageClauses(pck), " "))
+	w.deprecation(pck.PackagePragmas())
+	w.comment(pck.Doc)
+	w.line()
+
+	if !all {
+		for _, vd := range pck.VarDecls {
+			w.line(textIndent, compact(vd.String()))
+		}
+
+		for _, fd := range pck.FuncSpecs {
+			w.line(textIndent, compact(textLister.funcSignature(fd).String()))
+		}
+
+		for _, td := range pck.TypeDecls {
+			w.line(textIndent, compact(textLister.typeSignature(td).String()))
+		}
+
+		for _, sd := range pck.SubtypeDecls {
+			w.line(textIndent, compact(textLister.subtypeSignature(sd).String()))
+		}
+
+		for _, cd := range pck.CursorDecls {
+			w.line(textIndent, compact(textLister.cursorSignature(cd).String()))
+		}
+
+		return
+	}
+
+	for _, vd := range pck.VarDecls {
+		w.decl(vd.String(), vd.Doc)
+	}
+
+	for _, g := range funcGroups(pck.FuncSpecs) {
+		w.funcGroup(g)
+	}
+
+	for _, td := range pck.TypeDecls {
+		w.typeDecl(td)
+	}
+
+	for _, sd := range pck.SubtypeDecls {
+		w.decl(textLister.subtypeSignature(sd).String(), sd.Doc)
+	}
+
+	for _, cd := range pck.CursorDecls {
+		w.decl(textLister.cursorSignature(cd).String(), cd.Doc)
+	}
+}
+
+// Writes the declarations of the package with the name.
+// Returns false if there are none.
+func (w *textWriter) pckMember(pck *ast.Package, name string) bool {
+	found := false
+
+	for _, vd := range pck.VarDecls {
+		if strings.EqualFold(vd.Name.Name, name) {
+			w.decl(vd.String(), vd.Doc)
+			found = true
+		}
+	}
+
+	for _, g := range funcGroups(pck.FuncSpecs) {
+		if strings.EqualFold(g.Name, name) {
+			w.funcGroup(g)
+			found = true
+		}
+	}
+
+	for _, td := range pck.TypeDecls {
+		if strings.EqualFold(td.Name.Name, name) {
+			w.typeDecl(td)
+			found = true
+		}
+	}
+
+	for _, sd := range pck.SubtypeDecls {
+		if strings.EqualFold(sd.Name.Name, name) {
+			w.decl(textLister.subtypeSignature(sd).String(), sd.Doc)
+			found = true
+		}
+	}
+
+	for _, cd := range pck.CursorDecls {
+		if strings.EqualFold(cd.Name.Name, name) {
+			w.decl(textLister.cursorSignature(cd).String(), cd.Doc)
+			found = true
+		}
+	}
+
+	return found
+}
+
+// Writes the object type's doc. With all, methods are written
+// with their docs, otherwise only their signatures on one line.
+func (w *textWriter) objectType(t *ast.ObjectType, all bool) {
+	w.line(textLister.objectTypeSignature(t).String())
+	w.comment(t.Doc)
+	w.fields(t.Attrs)
+	w.line()
+
+	for _, g := range methodGroups(t.Methods) {
+		if all {
+			w.funcGroup(g)
+			continue
+		}
+
+		for _, item := range g.Items {
+			w.line(textIndent, compact(textLister.methodSignature(item.Method).String()))
+		}
+	}
+}
+
+// Writes the methods of the object type with the name.
+// Returns false if there are none.
+func (w *textWriter) method(t *ast.ObjectType, name string) bool {
+	for _, g := range methodGroups(t.Methods) {
+		if strings.EqualFold(g.Name, name) {
+			w.funcGroup(g)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Writes the list of packages, types and standalone subprograms
+func (w *textWriter) index(f *ast.Files) {
+	for _, pck := range f.GetPackages() {
+		w.line("package ", packageFullName(pck), summarySuffix(pck.Doc))
+	}
+
+	for _, t := range f.GetTypes() {
+		w.line("type ", t.Name.Name, summarySuffix(t.Doc))
+	}
+
+	for _, g := range funcGroups(f.GetFuncs()) {
+		w.line(funcHeader(g.Items[0].Spec), " ", groupLabel(g), summarySuffix(g.Items[0].Spec.Doc))
+	}
+}
+
+// Returns the number of the name's parts that name the package,
+// like 1 for "pck.func" and 2 for "schema.pck.func", or 0 if
+// the name doesn't start with the package's name
+func matchPackage(pck *ast.Package, parts []string) int {
+	if len(parts) > 1 && pck.Schema != nil &&
+		strings.EqualFold(pck.Schema.Name, parts[0]) && strings.EqualFold(pck.Name.Name, parts[1]) {
+		return 2
+	}
+
+	if strings.EqualFold(pck.Name.Name, parts[0]) {
+		return 1
+	}
+
+	return 0
+}
+
+// Looks up the declarations with the name, like "pck", "pck.func",
+// "schema.pck.func", "obj_type", "obj_type.method" or the name of
+// a standalone subprogram, and writes their docs. The lookup is
+// case-insensitive. An unqualified name that isn't a package, a type
+// or a standalone subprogram is looked up in all packages and types.
+// Overloaded subprograms are written together. An empty name
+// lists all packages, types and standalone subprograms.
+// With all, packages and types are written with the docs of
+// all their declarations.
+func WriteDoc(out io.Writer, f *ast.Files, name string, all bool) error {
+	w := &textWriter{}
+
+	if name == "" {
+		w.index(f)
+		_, err := io.WriteString(out, w.b.String())
+		return err
+	}
+
+	parts := strings.Split(name, ".")
+	last := parts[len(parts)-1]
+	found := false
+
+	for _, pck := range f.GetPackages() {
+		switch n := matchPackage(pck, parts); {
+		case n == 0:
+		case n == len(parts):
+			w.pck(pck, all)
+			found = true
+		case n == len(parts)-1:
+			found = w.pckMember(pck, last) || found
+		}
+	}
+
+	for _, t := range f.GetTypes() {
+		if !strings.EqualFold(t.Name.Name, parts[0]) {
+			continue
+		}
+
+		switch len(parts) {
+		case 1:
+			w.objectType(t, all)
+			found = true
+		case 2:
+			found = w.method(t, last) || found
+		}
+	}
+
+	if len(parts) == 1 {
+		for _, g := range funcGroups(f.GetFuncs()) {
+			if strings.EqualFold(g.Name, name) {
+				w.funcGroup(g)
+				found = true
+			}
+		}
+	}
+
+	if !found && len(parts) == 1 {
+		for _, pck := range f.GetPackages() {
+			found = w.pckMember(pck, name) || found
+		}
+
+		for _, t := range f.GetTypes() {
+			found = w.method(t, name) || found
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("no declaration named %s", name)
+	}
+
+	_, err := io.WriteString(out, strings.TrimRight(w.b.String(), "\n")+"\n")
+	return err
+}
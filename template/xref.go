@@ -0,0 +1,414 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"github.com/cyevgeniy/pldoc/ast"
+	"github.com/cyevgeniy/pldoc/doc"
+	"github.com/cyevgeniy/pldoc/token"
+	"regexp"
+	"strings"
+)
+
+// Names of predefined types and keywords that appear in type
+// declarations. They are never reported as unresolved.
+var builtinTypes = map[string]bool{
+	"number": true, "integer": true, "int": true, "smallint": true,
+	"decimal": true, "dec": true, "numeric": true, "float": true,
+	"real": true, "double": true, "precision": true,
+	"binary_integer": true, "pls_integer": true, "simple_integer": true,
+	"natural": true, "naturaln": true, "positive": true, "positiven": true,
+	"signtype": true, "binary_float": true, "binary_double": true,
+	"simple_float": true, "simple_double": true,
+	"varchar2": true, "varchar": true, "char": true, "character": true,
+	"nchar": true, "nvarchar2": true, "string": true, "long": true,
+	"raw": true, "rowid": true, "urowid": true, "byte": true,
+	"clob": true, "nclob": true, "blob": true, "bfile": true,
+	"date": true, "timestamp": true, "with": true, "local": true,
+	"time": true, "zone": true, "interval": true, "year": true,
+	"month": true, "day": true, "second": true, "to": true,
+	"boolean": true, "sys_refcursor": true, "xmltype": true,
+	"anydata": true, "anytype": true, "json": true,
+	"index": true, "by": true, "of": true, "ref": true, "not": true,
+	"null": true, "self": true, "as": true, "result": true,
+	"varying": true, "array": true,
+}
+
+// Identifiers and qualified names in type declarations,
+// like t_rec, pck.t_tab or "MyType"
+var typeNameRe = regexp.MustCompile(`(?:[A-Za-z_][\w$#]*|"[^"]+")(?:\.(?:[A-Za-z_][\w$#]*|"[^"]+"))*`)
+
+// Resolves names of declarations to the pages and anchors
+// where they are documented. Type names in signatures are
+// resolved among types only, names in comments among all
+// declarations.
+type crossRefs struct {
+	pcks  []*ast.Package
+	objs  []*ast.ObjectType
+	types map[string]string // by lowercase names, like "pck.t_rec" or "t_obj"
+	decls map[string]string // all declarations, including types
+
+	// Last parts of the names that weren't resolved when
+	// the references were checked, like "t_rec" for "pck.t_rec"
+	missed map[string]bool
+}
+
+func newCrossRefs(f *ast.Files) *crossRefs {
+	r := &crossRefs{
+		pcks:   f.GetPackages(),
+		objs:   f.GetTypes(),
+		types:  make(map[string]string),
+		decls:  make(map[string]string),
+		missed: make(map[string]bool),
+	}
+
+	for _, pck := range r.pcks {
+		page := pck.Name.Name + ".html"
+		r.add(r.decls, pck, "", page)
+
+		for _, vd := range pck.VarDecls {
+			r.add(r.decls, pck, vd.Name.Name, page+"#var_"+vd.Name.Name)
+		}
+
+		for _, g := range funcGroups(pck.FuncSpecs) {
+			r.add(r.decls, pck, g.Name, page+"#"+g.Anchor)
+		}
+
+		for _, td := range pck.TypeDecls {
+			r.add(r.types, pck, td.Name.Name, page+"#type_"+td.Name.Name)
+			r.add(r.decls, pck, td.Name.Name, page+"#type_"+td.Name.Name)
+		}
+
+		for _, sd := range pck.SubtypeDecls {
+			r.add(r.types, pck, sd.Name.Name, page+"#subtype_"+sd.Name.Name)
+			r.add(r.decls, pck, sd.Name.Name, page+"#subtype_"+sd.Name.Name)
+		}
+
+		for _, cd := range pck.CursorDecls {
+			r.add(r.decls, pck, cd.Name.Name, page+"#cursor_"+cd.Name.Name)
+		}
+	}
+
+	for _, t := range r.objs {
+		name := strings.ToLower(t.Name.Name)
+		page := t.Name.Name + ".html"
+		r.types[name] = page
+		r.decls[name] = page
+
+		for _, g := range methodGroups(t.Methods) {
+			r.decls[name+"."+strings.ToLower(g.Name)] = page + "#" + g.Anchor
+		}
+	}
+
+	for _, g := range funcGroups(f.GetFuncs()) {
+		r.decls[strings.ToLower(g.Name)] = subprogramsPage + "#" + g.Anchor
+	}
+
+	return r
+}
+
+// Adds the package's declaration to the map. It can be referenced
+// by its name qualified with the package's name and, optionally,
+// the schema. An empty name adds the package itself.
+func (r *crossRefs) add(m map[string]string, pck *ast.Package, name string, url string) {
+	qualified := strings.ToLower(pck.Name.Name)
+	if name != "" {
+		qualified += "." + strings.ToLower(name)
+	}
+	m[qualified] = url
+
+	if pck.Schema != nil {
+		m[strings.ToLower(pck.Schema.Name)+"."+qualified] = url
+	}
+}
+
+// Returns the lowercase name of the package or the object type
+// whose specification or doc contains the position, or an empty
+// string if it's outside of all of them
+func (r *crossRefs) scope(pos token.Pos) string {
+	if !pos.IsValid() {
+		return ""
+	}
+
+	contains := func(doc *ast.CommentGroup, n ast.Node) bool {
+		start := n.Start()
+		if doc != nil {
+			start = doc.Start()
+		}
+
+		return start <= pos && pos < n.End()
+	}
+
+	for _, pck := range r.pcks {
+		if contains(pck.Doc, pck) {
+			return strings.ToLower(pck.Name.Name)
+		}
+	}
+
+	for _, t := range r.objs {
+		if contains(t.Doc, t) {
+			return strings.ToLower(t.Name.Name)
+		}
+	}
+
+	return ""
+}
+
+// Returns the URL of the type with the name that is referenced
+// at the position pos
+func (r *crossRefs) resolveType(name string, pos token.Pos) (string, bool) {
+	return r.resolve(r.types, name, pos)
+}
+
+// Returns the URL of the declaration with the name
+// that is referenced at the position pos
+func (r *crossRefs) resolveDecl(name string, pos token.Pos) (string, bool) {
+	return r.resolve(r.decls, name, pos)
+}
+
+// Looks up the name in the map. Unqualified names are looked
+// up in the enclosing package or object type first. Names qualified with
+// a schema that isn't known are looked up without the schema.
+func (r *crossRefs) resolve(m map[string]string, name string, pos token.Pos) (string, bool) {
+	name = strings.ToLower(name)
+
+	if scope := r.scope(pos); scope != "" && !strings.Contains(name, ".") {
+		if url, ok := m[scope+"."+name]; ok {
+			return url, true
+		}
+	}
+
+	for {
+		if url, ok := m[name]; ok {
+			return url, true
+		}
+
+		i := strings.Index(name, ".")
+		if i < 0 || r.isPackage(name[:i]) {
+			return "", false
+		}
+
+		name = name[i+1:]
+	}
+}
+
+// Records the name that can't be resolved
+func (r *crossRefs) miss(name string) {
+	r.missed[lastPart(name)] = true
+}
+
+func lastPart(name string) string {
+	name = strings.ToLower(name)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+
+	return name
+}
+
+// Reports whether pages that are generated with the refs link
+// the same way as with the old ones: all declarations keep their
+// URLs, and new ones have none of the names that weren't resolved
+func (r *crossRefs) sameLinks(old *crossRefs) bool {
+	for key, url := range old.decls {
+		if r.decls[key] != url {
+			return false
+		}
+	}
+
+	for key := range r.decls {
+		if _, ok := old.decls[key]; !ok && old.missed[lastPart(key)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *crossRefs) isPackage(name string) bool {
+	for _, pck := range r.pcks {
+		if strings.EqualFold(pck.Name.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns locations of type names in the text. Names that are
+// followed by %type or %rowtype refer to tables and variables,
+// so they are skipped with the attribute, as well as predefined types.
+func typeNames(text string) [][]int {
+	var res [][]int
+
+	for _, loc := range typeNameRe.FindAllStringIndex(text, -1) {
+		name := strings.ToLower(text[loc[0]:loc[1]])
+
+		if loc[1] < len(text) && text[loc[1]] == '%' || loc[0] > 0 && text[loc[0]-1] == '%' {
+			continue
+		}
+
+		if builtinTypes[name] || strings.HasPrefix(name, "sys.") {
+			continue
+		}
+
+		res = append(res, loc)
+	}
+
+	return res
+}
+
+// Reports doc links in the comment that don't refer to any declaration
+func (r *crossRefs) checkComment(cg *ast.CommentGroup, fset *token.FileSet, warn func(pos token.Position, msg string)) {
+	if cg == nil {
+		return
+	}
+
+	check := func(text []doc.Text) {
+		for _, t := range text {
+			if link, ok := t.(*doc.DocLink); ok {
+				if _, ok := r.resolveDecl(link.Name, cg.Start()); !ok {
+					r.miss(link.Name)
+					warn(fset.Position(cg.Start()), "unresolved doc link ["+link.Name+"]")
+				}
+			}
+		}
+	}
+
+	for _, b := range doc.Parse(cg.Text()).Blocks {
+		switch b := b.(type) {
+		case *doc.Paragraph:
+			check(b.Text)
+		case *doc.Heading:
+			check(b.Text)
+		case *doc.List:
+			for _, item := range b.Items {
+				check(item.Text)
+			}
+		}
+	}
+}
+
+// Reports unresolved doc links in the comments of all declarations
+func (r *crossRefs) checkDocLinks(f *ast.Files, warn func(pos token.Position, msg string)) {
+	comment := func(cg *ast.CommentGroup) {
+		r.checkComment(cg, f.FileSet, warn)
+	}
+
+	for _, pck := range f.GetPackages() {
+		comment(pck.Doc)
+
+		for _, vd := range pck.VarDecls {
+			comment(vd.Doc)
+		}
+
+		for _, fs := range pck.FuncSpecs {
+			comment(fs.Doc)
+		}
+
+		for _, td := range pck.TypeDecls {
+			comment(td.Doc)
+		}
+
+		for _, sd := range pck.SubtypeDecls {
+			comment(sd.Doc)
+		}
+
+		for _, cd := range pck.CursorDecls {
+			comment(cd.Doc)
+		}
+	}
+
+	for _, t := range r.objs {
+		comment(t.Doc)
+
+		for _, m := range t.Methods {
+			comment(m.Spec.Doc)
+		}
+	}
+
+	for _, fs := range f.GetFuncs() {
+		comment(fs.Doc)
+	}
+}
+
+// Reports type names that don't refer to any parsed type
+func (r *crossRefs) checkIdent(t *ast.Ident, fset *token.FileSet, warn func(pos token.Position, msg string)) {
+	if t == nil {
+		return
+	}
+
+	for _, loc := range typeNames(t.Name) {
+		name := t.Name[loc[0]:loc[1]]
+		if _, ok := r.resolveType(name, t.Start()); !ok {
+			r.miss(name)
+			warn(fset.Position(t.Start()), "unresolved type reference "+name)
+		}
+	}
+}
+
+// Reports unresolved type references in
+// the signatures of all declarations
+func (r *crossRefs) checkTypes(f *ast.Files, warn func(pos token.Position, msg string)) {
+	ident := func(t *ast.Ident) {
+		r.checkIdent(t, f.FileSet, warn)
+	}
+
+	fields := func(list []*ast.Field) {
+		for _, field := range list {
+			if field.Kind == ast.VPar {
+				ident(field.T)
+			}
+		}
+	}
+
+	fieldList := func(fl *ast.FieldList) {
+		if fl != nil {
+			fields(fl.List)
+		}
+	}
+
+	funcSpec := func(fs *ast.FuncSpec) {
+		fieldList(fs.Params)
+		if fs.Ftype == ast.FtFunc {
+			ident(fs.T)
+		}
+		ident(fs.PipelinedUsing)
+		ident(fs.AggregateUsing)
+	}
+
+	for _, pck := range f.GetPackages() {
+		for _, fs := range pck.FuncSpecs {
+			funcSpec(fs)
+		}
+
+		for _, td := range pck.TypeDecls {
+			fieldList(td.Params)
+			ident(td.T)
+		}
+
+		for _, sd := range pck.SubtypeDecls {
+			ident(sd.Base)
+		}
+
+		for _, cd := range pck.CursorDecls {
+			fieldList(cd.Params)
+			ident(cd.T)
+		}
+	}
+
+	for _, t := range f.GetTypes() {
+		ident(t.Super)
+		fields(t.Attrs)
+
+		for _, m := range t.Methods {
+			funcSpec(m.Spec)
+		}
+	}
+
+	for _, fs := range f.GetFuncs() {
+		funcSpec(fs)
+	}
+}
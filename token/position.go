@@ -7,6 +7,8 @@ package token
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 )
 
 type Position struct {
@@ -16,53 +18,206 @@ type Position struct {
 	Column   int    // Column number, starting at 1
 }
 
-// For a file, this is the offset.
-// It may be converted to Position, if needed.
+// IsValid reports whether the position is valid.
+func (pos Position) IsValid() bool { return pos.Line > 0 }
+
+func (pos Position) String() string {
+	return fmt.Sprintf("File: %s; Line: %d; Position: %d", pos.Filename, pos.Line, pos.Column)
+}
+
+// Pos is a compact encoding of a source position within a file set.
+// It's the offset in the file plus the file's base, so positions
+// of different files in the same set never overlap. It may be
+// converted to Position with FileSet.Position or File.Position.
 type Pos int
 
+// NoPos is the zero value of Pos. There is no file
+// and line information associated with it.
 const NoPos Pos = 0
 
-func (pos Position) String() string {
-	return fmt.Sprintf("File: %s; Line: %d; Position: %d", pos.Filename, pos.Line, pos.Column)
+// IsValid reports whether the position is valid.
+func (p Pos) IsValid() bool {
+	return p != NoPos
 }
 
+// File is a handle for a file that belongs to a FileSet.
+// Positions of the file are in the [base, base+size] range.
 type File struct {
 	Filename string
-	lines    []int // offsets of the first characters for each line(first is always 0)
+	base     int
+	size     int
+
+	mutex sync.Mutex
+	lines []int // offsets of the first characters for each line(first is always 0)
+}
+
+// Returns the base offset of the file
+func (f *File) Base() int {
+	return f.base
 }
 
-func NewFile(filename string) *File {
-	return &File{Filename: filename, lines: []int{0}}
+// Returns the size of the file in bytes
+func (f *File) Size() int {
+	return f.size
 }
 
+// Returns the number of lines that have been added so far
+func (f *File) LineCount() int {
+	f.mutex.Lock()
+	n := len(f.lines)
+	f.mutex.Unlock()
+
+	return n
+}
+
+// Adds the offset of a new line. Offsets that aren't greater
+// than the previous line's offset or are beyond the end of the
+// file are ignored.
 func (f *File) AddLine(offs int) {
-	if f.lines[len(f.lines)-1] < offs {
+	f.mutex.Lock()
+	if f.lines[len(f.lines)-1] < offs && offs < f.size {
 		f.lines = append(f.lines, offs)
 	}
+	f.mutex.Unlock()
 }
 
+// Returns the Pos value for the offset in the file
 func (f *File) Pos(offs int) Pos {
-	return Pos(offs)
+	if offs > f.size {
+		panic(fmt.Sprintf("invalid file offset %d (should be <= %d)", offs, f.size))
+	}
+
+	return Pos(f.base + offs)
 }
 
-func (f *File) Line(pos Pos) int {
-	// Later this search should be optimized
-	var i int
-	for i = len(f.lines) - 1; i > 0; i-- {
-		if int(pos) >= f.lines[i] {
-			break
-		}
+// Returns the offset in the file for the position p
+func (f *File) Offset(p Pos) int {
+	if int(p) < f.base || int(p) > f.base+f.size {
+		panic(fmt.Sprintf("invalid Pos value %d (should be in [%d, %d])", p, f.base, f.base+f.size))
+	}
+
+	return int(p) - f.base
+}
+
+// Returns the line number for the position p
+func (f *File) Line(p Pos) int {
+	return f.Position(p).Line
+}
+
+// Returns the position of the first character of the line
+func (f *File) LineStart(line int) Pos {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if line < 1 || line > len(f.lines) {
+		panic(fmt.Sprintf("invalid line number %d (should be in [1, %d])", line, len(f.lines)))
+	}
+
+	return Pos(f.base + f.lines[line-1])
+}
+
+// Returns the Position value for the position p. If p is NoPos,
+// the result is the zero Position.
+func (f *File) Position(p Pos) Position {
+	if p == NoPos {
+		return Position{}
+	}
+
+	offs := f.Offset(p)
+
+	f.mutex.Lock()
+	i := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offs }) - 1
+	lineStart := f.lines[i]
+	f.mutex.Unlock()
+
+	return Position{
+		Filename: f.Filename,
+		Offset:   offs,
+		Line:     i + 1,
+		Column:   offs - lineStart + 1,
+	}
+}
+
+// FileSet represents a set of source files. Each file
+// gets its own range of positions in the set.
+type FileSet struct {
+	mutex sync.RWMutex
+	base  int     // base offset for the next file
+	files []*File // files in the order they were added
+}
+
+// Creates a new file set
+func NewFileSet() *FileSet {
+	// Positions start at 1 so that NoPos
+	// never matches a real position
+	return &FileSet{base: 1}
+}
+
+// Returns the minimum base offset that must
+// be provided to AddFile when adding the next file
+func (s *FileSet) Base() int {
+	s.mutex.RLock()
+	b := s.base
+	s.mutex.RUnlock()
+
+	return b
+}
+
+// Adds a new file with the given name and size to the set.
+// If base is negative, the current value of Base() is used.
+// The base must not be smaller than Base(), so files don't overlap.
+// The position base+size is valid too, it's the position of EOF.
+func (s *FileSet) AddFile(filename string, base, size int) *File {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if base < 0 {
+		base = s.base
 	}
 
-	return i + 1
+	if base < s.base {
+		panic(fmt.Sprintf("invalid base %d (should be >= %d)", base, s.base))
+	}
+
+	if size < 0 {
+		panic(fmt.Sprintf("invalid size %d (should be >= 0)", size))
+	}
+
+	f := &File{Filename: filename, base: base, size: size, lines: []int{0}}
+
+	// One more for EOF
+	s.base = base + size + 1
+	s.files = append(s.files, f)
+
+	return f
 }
 
-func (f *File) Position(p Pos) *Position {
-	pos := Position{}
+// Returns the file that contains the position p,
+// or nil if there is no such file in the set
+func (s *FileSet) File(p Pos) *File {
+	if p == NoPos {
+		return nil
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	i := sort.Search(len(s.files), func(i int) bool { return s.files[i].base > int(p) }) - 1
+	if i >= 0 {
+		if f := s.files[i]; int(p) <= f.base+f.size {
+			return f
+		}
+	}
 
-	pos.Line = f.Line(p)
-	pos.Column = int(p) - f.lines[pos.Line-1] + 1
-	pos.Filename = f.Filename
+	return nil
+}
+
+// Converts the position p to the Position value.
+// Returns the zero Position if p isn't in the set.
+func (s *FileSet) Position(p Pos) Position {
+	if f := s.File(p); f != nil {
+		return f.Position(p)
+	}
 
-	return &pos
+	return Position{}
 }
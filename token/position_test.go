@@ -0,0 +1,52 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package token
+
+import (
+	"testing"
+)
+
+func TestFileSetPosition(t *testing.T) {
+	fset := NewFileSet()
+
+	f1 := fset.AddFile("a.pks", -1, 10)
+	f1.AddLine(4)
+
+	f2 := fset.AddFile("b.pks", -1, 5)
+	f2.AddLine(2)
+
+	if f1.Base() != 1 || f2.Base() != 12 {
+		t.Fatalf("FileSet exception. Wrong bases: %d, %d", f1.Base(), f2.Base())
+	}
+
+	cases := []struct {
+		p    Pos
+		file string
+		line int
+		col  int
+	}{
+		{f1.Pos(0), "a.pks", 1, 1},
+		{f1.Pos(5), "a.pks", 2, 2},
+		{f1.Pos(10), "a.pks", 2, 7}, // EOF
+		{f2.Pos(0), "b.pks", 1, 1},
+		{f2.Pos(3), "b.pks", 2, 2},
+	}
+
+	for _, c := range cases {
+		pos := fset.Position(c.p)
+		if pos.Filename != c.file || pos.Line != c.line || pos.Column != c.col {
+			t.Fatalf("FileSet exception. Expected %s:%d:%d; Got %s:%d:%d",
+				c.file, c.line, c.col, pos.Filename, pos.Line, pos.Column)
+		}
+	}
+
+	if fset.File(NoPos) != nil || fset.Position(NoPos).IsValid() {
+		t.Fatalf("FileSet exception. NoPos shouldn't belong to a file")
+	}
+
+	if fset.File(Pos(f2.Base()+f2.Size()+1)) != nil {
+		t.Fatalf("FileSet exception. Position after the last file shouldn't belong to a file")
+	}
+}
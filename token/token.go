@@ -12,6 +12,7 @@ type Token int
 const (
 	EOF Token = iota
 	COMMENT
+	ILLEGAL
 
 	literal_start
 	IDENT  // identifiers
@@ -94,6 +95,9 @@ const (
 	AUTHID        // authid
 	CURRENT_USER  // current_user
 	DEFINER       // definer
+	CASE          // case
+	LOOP          // loop
+	SUBTYPE       // subtype
 
 	keywords_end
 )
@@ -101,6 +105,7 @@ const (
 var tokens = [...]string{
 	EOF:     "EOF",
 	COMMENT: "COMMENT",
+	ILLEGAL: "ILLEGAL",
 
 	IDENT:  "IDENT",
 	NUMBER: "NUMBER",
@@ -177,6 +182,9 @@ var tokens = [...]string{
 	AUTHID:        "authid",
 	CURRENT_USER:   "current_user",
 	DEFINER:        "definer",
+	CASE:          "case",
+	LOOP:          "loop",
+	SUBTYPE:       "subtype",
 }
 
 var keywords map[string]Token
@@ -0,0 +1,103 @@
+// Copyright 2022 Yevgeniy Chaban.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/cyevgeniy/pldoc/ast"
+	"github.com/cyevgeniy/pldoc/scanner"
+	"github.com/cyevgeniy/pldoc/template"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Regenerates the documentation in the directory when the sources
+// change. HTML pages that aren't affected by the changes are kept,
+// other formats are regenerated as a whole.
+type watcher struct {
+	src  *sources
+	dir  string
+	opts template.Options
+
+	// Generates the documentation in formats other than HTML
+	execute func(dir string, f *ast.Files, opts template.Options) error
+
+	site *template.Site // the last generated HTML documentation
+
+	// Changed files whose documentation failed to be
+	// written, like sources that changed again before
+	// their pages were rendered
+	pending []string
+}
+
+// Generates the documentation after the files have changed.
+// All pages are generated the first time.
+func (w *watcher) generate(changed []string) {
+	changed = append(w.pending, changed...)
+	w.pending = changed
+
+	f, errs := w.src.parsed()
+
+	var warnings scanner.ErrorList
+	opts := w.opts
+	opts.Paths = w.src.paths
+	opts.Warnings = &warnings
+
+	if w.execute != nil {
+		if err := w.execute(w.dir, f, opts); err != nil {
+			log.Print(err)
+			return
+		}
+
+		w.pending = nil
+		printProblems(errs, warnings)
+		return
+	}
+
+	site, err := template.NewSite(f, opts)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	printProblems(errs, warnings)
+
+	if err = os.MkdirAll(w.dir, 0750); err != nil {
+		log.Print(err)
+		return
+	}
+
+	pages := site.Affected(w.site, changed)
+	for _, name := range pages {
+		if err = site.WritePage(w.dir, name); err != nil {
+			log.Print(err)
+			return
+		}
+	}
+
+	w.pending = nil
+
+	// Pages of removed packages and types
+	if w.site != nil {
+		current := make(map[string]bool)
+		for _, name := range site.Pages() {
+			current[name] = true
+		}
+
+		for _, name := range w.site.Pages() {
+			if !current[name] {
+				if err = os.Remove(filepath.Join(w.dir, name)); err != nil && !os.IsNotExist(err) {
+					log.Print(err)
+				}
+			}
+		}
+	}
+
+	w.site = site
+
+	if changed != nil {
+		log.Printf("%d file(s) changed, %d page(s) regenerated", len(changed), len(pages))
+	}
+}